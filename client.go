@@ -0,0 +1,692 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+)
+
+const (
+	giteeAPIEndpoint = "https://gitee.com/api/v5"
+	giteePerPage     = 100
+)
+
+// giteeClient implements the apis of gitee. Each call can be cancelled by the context.
+type giteeClient struct {
+	getToken func() []byte
+	hc       http.Client
+}
+
+func newGiteeClient(getToken func() []byte) *giteeClient {
+	return &giteeClient{
+		getToken: getToken,
+		hc:       http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *giteeClient) GetRef(ctx context.Context, org, repo, ref string) (string, error) {
+	var v struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	err := c.do(
+		ctx, http.MethodGet,
+		fmt.Sprintf("/repos/%s/%s/branches/%s", org, repo, url.PathEscape(ref)),
+		nil, &v,
+	)
+
+	return v.Commit.SHA, err
+}
+
+func (c *giteeClient) GetRepo(ctx context.Context, org, repo string) (sdk.Project, error) {
+	var v sdk.Project
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s", org, repo), nil, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) GetRepos(ctx context.Context, org string) ([]sdk.Project, error) {
+	var r []sdk.Project
+	err := c.listAll(ctx, fmt.Sprintf("/orgs/%s/repos?type=all", org), func(data []byte) (int, error) {
+		var v []sdk.Project
+		if err := json.Unmarshal(data, &v); err != nil {
+			return 0, err
+		}
+
+		r = append(r, v...)
+
+		return len(v), nil
+	})
+
+	return r, err
+}
+
+func (c *giteeClient) CreateRepo(ctx context.Context, org string, repo sdk.RepositoryPostParam) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/orgs/%s/repos", org), repo, nil)
+}
+
+func (c *giteeClient) UpdateRepo(ctx context.Context, org, repo string, info sdk.RepoPatchParam) error {
+	return c.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/%s", org, repo), info, nil)
+}
+
+func (c *giteeClient) SetRepoReviewer(ctx context.Context, org, repo string, reviewer sdk.SetRepoReviewer) error {
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("/repos/%s/%s/reviewer", org, repo), reviewer, nil)
+}
+
+func (c *giteeClient) AddRepoMember(ctx context.Context, org, repo, login, permission string) error {
+	return c.do(
+		ctx, http.MethodPut,
+		fmt.Sprintf("/repos/%s/%s/collaborators/%s", org, repo, login),
+		map[string]string{"permission": permission},
+		nil,
+	)
+}
+
+func (c *giteeClient) RemoveRepoMember(ctx context.Context, org, repo, login string) error {
+	return c.do(
+		ctx, http.MethodDelete,
+		fmt.Sprintf("/repos/%s/%s/collaborators/%s", org, repo, login),
+		nil, nil,
+	)
+}
+
+func (c *giteeClient) GetRepoAllBranch(ctx context.Context, org, repo string) ([]sdk.Branch, error) {
+	var v []sdk.Branch
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/branches", org, repo), nil, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) CreateBranch(ctx context.Context, org, repo, branch, parentBranch string) error {
+	return c.do(
+		ctx, http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/branches", org, repo),
+		map[string]string{"refs": parentBranch, "branch_name": branch},
+		nil,
+	)
+}
+
+func (c *giteeClient) SetProtectionBranch(ctx context.Context, org, repo, branch string) error {
+	return c.do(
+		ctx, http.MethodPut,
+		fmt.Sprintf("/repos/%s/%s/branches/%s/protection", org, repo, url.PathEscape(branch)),
+		nil, nil,
+	)
+}
+
+func (c *giteeClient) CancelProtectionBranch(ctx context.Context, org, repo, branch string) error {
+	return c.do(
+		ctx, http.MethodDelete,
+		fmt.Sprintf("/repos/%s/%s/branches/%s/protection", org, repo, url.PathEscape(branch)),
+		nil, nil,
+	)
+}
+
+func (c *giteeClient) GetPathContent(ctx context.Context, org, repo, path, ref string) (sdk.Content, error) {
+	var v sdk.Content
+	err := c.do(
+		ctx, http.MethodGet,
+		fmt.Sprintf("/repos/%s/%s/contents/%s?ref=%s", org, repo, escapePath(path), url.QueryEscape(ref)),
+		nil, &v,
+	)
+
+	return v, err
+}
+
+func (c *giteeClient) CreateFile(
+	ctx context.Context, org, repo, branch, path, content, commitMsg string,
+) (sdk.CommitContent, error) {
+	var v sdk.CommitContent
+	err := c.do(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/contents/%s", org, repo, escapePath(path)),
+		fileParam{
+			Content: base64.StdEncoding.EncodeToString([]byte(content)),
+			Message: commitMsg,
+			Branch:  branch,
+		},
+		&v,
+	)
+
+	return v, err
+}
+
+func (c *giteeClient) GetDirectoryTree(ctx context.Context, org, repo, sha string, recursive int32) (sdk.Tree, error) {
+	var v sdk.Tree
+	err := c.do(
+		ctx, http.MethodGet,
+		fmt.Sprintf("/repos/%s/%s/git/trees/%s?recursive=%d", org, repo, sha, recursive),
+		nil, &v,
+	)
+
+	return v, err
+}
+
+type repoLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+func (c *giteeClient) ListRepoLabels(ctx context.Context, org, repo string) ([]repoLabel, error) {
+	var v []repoLabel
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/labels", org, repo), nil, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) CreateRepoLabel(ctx context.Context, org, repo string, label repoLabel) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/labels", org, repo), label, nil)
+}
+
+type repoWebhook struct {
+	URL                 string `json:"url"`
+	Password            string `json:"password,omitempty"`
+	PushEvents          bool   `json:"push_events"`
+	TagPushEvents       bool   `json:"tag_push_events"`
+	IssuesEvents        bool   `json:"issues_events"`
+	NoteEvents          bool   `json:"note_events"`
+	MergeRequestsEvents bool   `json:"merge_requests_events"`
+}
+
+func (c *giteeClient) ListRepoWebhooks(ctx context.Context, org, repo string) ([]repoWebhook, error) {
+	var v []repoWebhook
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/hooks", org, repo), nil, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) CreateRepoWebhook(ctx context.Context, org, repo string, hook repoWebhook) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/hooks", org, repo), hook, nil)
+}
+
+type fileParam struct {
+	Content string `json:"content,omitempty"`
+	SHA     string `json:"sha,omitempty"`
+	Message string `json:"message"`
+	Branch  string `json:"branch"`
+}
+
+func (c *giteeClient) UpdateFile(ctx context.Context, org, repo, branch, path, content, sha, commitMsg string) error {
+	return c.do(
+		ctx,
+		http.MethodPut,
+		fmt.Sprintf("/repos/%s/%s/contents/%s", org, repo, escapePath(path)),
+		fileParam{
+			Content: base64.StdEncoding.EncodeToString([]byte(content)),
+			SHA:     sha,
+			Message: commitMsg,
+			Branch:  branch,
+		},
+		nil,
+	)
+}
+
+func (c *giteeClient) DeleteFile(ctx context.Context, org, repo, branch, path, sha, commitMsg string) error {
+	q := url.Values{}
+	q.Set("sha", sha)
+	q.Set("message", commitMsg)
+	q.Set("branch", branch)
+
+	return c.do(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("/repos/%s/%s/contents/%s?%s", org, repo, escapePath(path), q.Encode()),
+		nil, nil,
+	)
+}
+
+type pullRequestRef struct {
+	Ref  string          `json:"ref"`
+	Sha  string          `json:"sha"`
+	Repo pullRequestRepo `json:"repo"`
+}
+
+type pullRequestRepo struct {
+	FullName string `json:"full_name"`
+}
+
+type pullRequest struct {
+	Number  int32          `json:"number"`
+	State   string         `json:"state"`
+	Title   string         `json:"title"`
+	Body    string         `json:"body"`
+	HTMLURL string         `json:"html_url"`
+	Head    pullRequestRef `json:"head"`
+	Base    pullRequestRef `json:"base"`
+}
+
+type pullRequestParam struct {
+	Title string `json:"title,omitempty"`
+	Head  string `json:"head,omitempty"`
+	Base  string `json:"base,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+func (c *giteeClient) ListPullRequests(ctx context.Context, org, repo, state, head, base string) ([]pullRequest, error) {
+	q := url.Values{}
+	q.Set("state", state)
+	if head != "" {
+		q.Set("head", head)
+	}
+	if base != "" {
+		q.Set("base", base)
+	}
+
+	var r []pullRequest
+	err := c.listAll(
+		ctx,
+		fmt.Sprintf("/repos/%s/%s/pulls?%s", org, repo, q.Encode()),
+		func(data []byte) (int, error) {
+			var v []pullRequest
+			if err := json.Unmarshal(data, &v); err != nil {
+				return 0, err
+			}
+
+			r = append(r, v...)
+
+			return len(v), nil
+		},
+	)
+
+	return r, err
+}
+
+func (c *giteeClient) CreatePullRequest(ctx context.Context, org, repo string, param pullRequestParam) (pullRequest, error) {
+	var v pullRequest
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/pulls", org, repo), param, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) GetPullRequest(ctx context.Context, org, repo string, number int32) (pullRequest, error) {
+	var r pullRequest
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/pulls/%d", org, repo, number), nil, &r)
+
+	return r, err
+}
+
+func (c *giteeClient) UpdatePullRequest(ctx context.Context, org, repo string, number int32, param pullRequestParam) error {
+	return c.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/%s/pulls/%d", org, repo, number), param, nil)
+}
+
+type importRepoParam struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+	ImportURL   string `json:"import_url"`
+	HasIssues   bool   `json:"has_issues"`
+	HasWiki     bool   `json:"has_wiki"`
+	CanComment  bool   `json:"can_comment"`
+	Private     bool   `json:"private"`
+}
+
+// ImportRepo creates a repo in the org by importing from the git url.
+func (c *giteeClient) ImportRepo(ctx context.Context, org string, param importRepoParam) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/orgs/%s/repos", org), param, nil)
+}
+
+// ForkRepo forks the repo to the org with the new name.
+func (c *giteeClient) ForkRepo(ctx context.Context, org, repo, newOrg, newRepo string) error {
+	return c.do(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/forks", org, repo),
+		map[string]string{
+			"organization": newOrg,
+			"name":         newRepo,
+			"path":         newRepo,
+		},
+		nil,
+	)
+}
+
+// TransferRepo transfers the repo to another org.
+func (c *giteeClient) TransferRepo(ctx context.Context, org, repo, newOrg string) error {
+	return c.do(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/transfer", org, repo),
+		map[string]string{"new_owner": newOrg},
+		nil,
+	)
+}
+
+func (c *giteeClient) CreateIssueComment(ctx context.Context, org, repo, number, comment string) error {
+	return c.do(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/issues/%s/comments", org, repo, number),
+		map[string]string{"body": comment},
+		nil,
+	)
+}
+
+type commitInfo struct {
+	SHA     string
+	Message string
+	Parents []string
+	Files   []string
+}
+
+// GetCommit returns the message, parents and changed files of commit.
+func (c *giteeClient) GetCommit(ctx context.Context, org, repo, sha string) (commitInfo, error) {
+	var r struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Message string `json:"message"`
+		} `json:"commit"`
+		Parents []struct {
+			SHA string `json:"sha"`
+		} `json:"parents"`
+		Files []struct {
+			Filename string `json:"filename"`
+		} `json:"files"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/commits/%s", org, repo, sha), nil, &r); err != nil {
+		return commitInfo{}, err
+	}
+
+	v := commitInfo{
+		SHA:     r.SHA,
+		Message: r.Commit.Message,
+		Parents: make([]string, len(r.Parents)),
+		Files:   make([]string, len(r.Files)),
+	}
+	for i := range r.Parents {
+		v.Parents[i] = r.Parents[i].SHA
+	}
+	for i := range r.Files {
+		v.Files[i] = r.Files[i].Filename
+	}
+
+	return v, nil
+}
+
+// AddPRComment comments on the pull request and returns the id of comment.
+func (c *giteeClient) AddPRComment(ctx context.Context, org, repo string, number int32, comment string) (int32, error) {
+	var r struct {
+		ID int32 `json:"id"`
+	}
+	err := c.do(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/repos/%s/%s/pulls/%d/comments", org, repo, number),
+		map[string]string{"body": comment},
+		&r,
+	)
+
+	return r.ID, err
+}
+
+func (c *giteeClient) EditPRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	return c.do(
+		ctx,
+		http.MethodPatch,
+		fmt.Sprintf("/repos/%s/%s/pulls/comments/%d", org, repo, commentID),
+		map[string]string{"body": comment},
+		nil,
+	)
+}
+
+// The team apis below are not in the public document of gitee api v5,
+// so syncing the sig teams which uses them is disabled by default.
+type prCommentInfo struct {
+	ID   int32  `json:"id"`
+	Body string `json:"body"`
+}
+
+func (c *giteeClient) ListPRComments(ctx context.Context, org, repo string, number int32) ([]prCommentInfo, error) {
+	var r []prCommentInfo
+	p := fmt.Sprintf("/repos/%s/%s/pulls/%d/comments", org, repo, number)
+	err := c.listAll(ctx, p, func(data []byte) (int, error) {
+		var v []prCommentInfo
+		if err := json.Unmarshal(data, &v); err != nil {
+			return 0, err
+		}
+
+		r = append(r, v...)
+
+		return len(v), nil
+	})
+
+	return r, err
+}
+
+type team struct {
+	ID          int32  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+func (c *giteeClient) ListTeams(ctx context.Context, org string) ([]team, error) {
+	var r []team
+	err := c.listAll(ctx, fmt.Sprintf("/orgs/%s/teams", org), func(data []byte) (int, error) {
+		var v []team
+		if err := json.Unmarshal(data, &v); err != nil {
+			return 0, err
+		}
+
+		r = append(r, v...)
+
+		return len(v), nil
+	})
+
+	return r, err
+}
+
+func (c *giteeClient) CreateTeam(ctx context.Context, org, name, desc string) error {
+	return c.do(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/orgs/%s/teams", org),
+		map[string]string{"name": name, "description": desc},
+		nil,
+	)
+}
+
+func (c *giteeClient) ListTeamMembers(ctx context.Context, org, teamName string) ([]string, error) {
+	var r []string
+	err := c.listAll(
+		ctx,
+		fmt.Sprintf("/orgs/%s/teams/%s/members", org, url.PathEscape(teamName)),
+		func(data []byte) (int, error) {
+			var v []userInfo
+			if err := json.Unmarshal(data, &v); err != nil {
+				return 0, err
+			}
+
+			for i := range v {
+				r = append(r, v[i].Login)
+			}
+
+			return len(v), nil
+		},
+	)
+
+	return r, err
+}
+
+func (c *giteeClient) AddTeamMember(ctx context.Context, org, teamName, login string) error {
+	return c.do(
+		ctx,
+		http.MethodPut,
+		fmt.Sprintf("/orgs/%s/teams/%s/members/%s", org, url.PathEscape(teamName), login),
+		nil, nil,
+	)
+}
+
+func (c *giteeClient) RemoveTeamMember(ctx context.Context, org, teamName, login string) error {
+	return c.do(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("/orgs/%s/teams/%s/members/%s", org, url.PathEscape(teamName), login),
+		nil, nil,
+	)
+}
+
+func (c *giteeClient) ListTeamRepos(ctx context.Context, org, teamName string) ([]string, error) {
+	var r []string
+	err := c.listAll(
+		ctx,
+		fmt.Sprintf("/orgs/%s/teams/%s/repos", org, url.PathEscape(teamName)),
+		func(data []byte) (int, error) {
+			var v []struct {
+				Path string `json:"path"`
+			}
+			if err := json.Unmarshal(data, &v); err != nil {
+				return 0, err
+			}
+
+			for i := range v {
+				r = append(r, v[i].Path)
+			}
+
+			return len(v), nil
+		},
+	)
+
+	return r, err
+}
+
+// AddTeamRepo grants the permission of repo to the team.
+func (c *giteeClient) AddTeamRepo(ctx context.Context, org, teamName, repo, permission string) error {
+	return c.do(
+		ctx,
+		http.MethodPut,
+		fmt.Sprintf("/orgs/%s/teams/%s/repos/%s/%s", org, url.PathEscape(teamName), org, repo),
+		map[string]string{"permission": permission},
+		nil,
+	)
+}
+
+func (c *giteeClient) RemoveTeamRepo(ctx context.Context, org, teamName, repo string) error {
+	return c.do(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("/orgs/%s/teams/%s/repos/%s/%s", org, url.PathEscape(teamName), org, repo),
+		nil, nil,
+	)
+}
+
+type userInfo struct {
+	Login string `json:"login"`
+	Name  string `json:"name,omitempty"`
+}
+
+func (c *giteeClient) GetUser(ctx context.Context, login string) (userInfo, error) {
+	var r userInfo
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(login), nil, &r)
+
+	return r, err
+}
+
+type httpStatusError struct {
+	statusCode int
+	msg        string
+}
+
+func (e *httpStatusError) Error() string {
+	return e.msg
+}
+
+func isNotFound(err error) bool {
+	v, ok := err.(*httpStatusError)
+
+	return ok && v.statusCode == http.StatusNotFound
+}
+
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// listAll gets the items of path page by page until a page is not full.
+// f decodes the items of a page and returns the number of them.
+func (c *giteeClient) listAll(ctx context.Context, path string, f func([]byte) (int, error)) error {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	for page := 1; ; page++ {
+		var v json.RawMessage
+
+		p := fmt.Sprintf("%s%sper_page=%d&page=%d", path, sep, giteePerPage, page)
+		if err := c.do(ctx, http.MethodGet, p, nil, &v); err != nil {
+			return err
+		}
+
+		if len(v) == 0 {
+			return nil
+		}
+
+		n, err := f(v)
+		if err != nil {
+			return err
+		}
+
+		if n < giteePerPage {
+			return nil
+		}
+	}
+}
+
+func (c *giteeClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var r io.Reader
+	if body != nil {
+		v, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(v)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, giteeAPIEndpoint+path, r)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json;charset=UTF-8")
+
+	q := req.URL.Query()
+	q.Set("access_token", string(c.getToken()))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	v, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &httpStatusError{
+			statusCode: resp.StatusCode,
+			msg: fmt.Sprintf(
+				"%s %s failed, status code:%d, body:%s",
+				method, path, resp.StatusCode, string(v),
+			),
+		}
+	}
+
+	if result == nil || len(v) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(v, result)
+}
@@ -0,0 +1,63 @@
+package community
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Identities maps the emails or alternate ids of users to their gitee logins.
+type Identities struct {
+	Identities []Identity `json:"identities,omitempty"`
+
+	logins map[string]string `json:"-"`
+}
+
+type Identity struct {
+	Login      string   `json:"login" required:"true"`
+	Emails     []string `json:"emails,omitempty"`
+	Alternates []string `json:"alternates,omitempty"`
+}
+
+// GetLogin returns the gitee login of the id which may be an email or alternate id.
+// It returns empty string if the id is not mapped.
+func (r *Identities) GetLogin(id string) string {
+	if r == nil {
+		return ""
+	}
+
+	return r.logins[strings.ToLower(id)]
+}
+
+func (r *Identities) Validate() error {
+	if r == nil {
+		return fmt.Errorf("empty identities")
+	}
+
+	v := make(map[string]string)
+	for i := range r.Identities {
+		item := &r.Identities[i]
+
+		if item.Login == "" {
+			return fmt.Errorf("validate %d identity, err:missing login", i)
+		}
+
+		login := strings.ToLower(item.Login)
+
+		ids := make([]string, 0, len(item.Emails)+len(item.Alternates))
+		ids = append(ids, item.Emails...)
+		ids = append(ids, item.Alternates...)
+
+		for _, id := range ids {
+			id = strings.ToLower(id)
+
+			if l, ok := v[id]; ok && l != login {
+				return fmt.Errorf("validate %d identity, err:%s is mapped to both %s and %s", i, id, l, login)
+			}
+			v[id] = login
+		}
+	}
+
+	r.logins = v
+
+	return nil
+}
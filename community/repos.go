@@ -2,7 +2,9 @@ package community
 
 import (
 	"fmt"
+	"path"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 )
@@ -12,12 +14,22 @@ const (
 	BranchProtected = "protected"
 )
 
+const (
+	RepoStatusIncubating = "incubating"
+	RepoStatusActive     = "active"
+	RepoStatusDeprecated = "deprecated"
+	RepoStatusArchived   = "archived"
+)
+
 type Repos struct {
 	Version      string       `json:"version,omitempty"`
 	Community    string       `json:"community" required:"true"`
 	Repositories []Repository `json:"repositories,omitempty"`
 
 	repos map[string]*Repository `json:"-"`
+
+	// renameConflicts records the reason why the repo can't be renamed.
+	renameConflicts map[string]string `json:"-"`
 }
 
 func (r *Repos) GetCommunity() string {
@@ -35,6 +47,16 @@ func (r *Repos) GetRepos() map[string]*Repository {
 	return r.repos
 }
 
+// GetRenameConflict returns the reason why the repo can't be renamed.
+// It returns empty string if there is no conflict.
+func (r *Repos) GetRenameConflict(repo string) string {
+	if r == nil {
+		return ""
+	}
+
+	return r.renameConflicts[repo]
+}
+
 func (r *Repos) Validate() error {
 	if r == nil {
 		return fmt.Errorf("empty repos")
@@ -69,24 +91,94 @@ func (r *Repos) convert() {
 	}
 
 	r.repos = v
+
+	r.convertRenameConflicts()
+}
+
+func (r *Repos) convertRenameConflicts() {
+	from := make(map[string][]string)
+
+	items := r.Repositories
+	for i := range items {
+		item := &items[i]
+
+		if n := item.RenameFrom; n != "" && n != item.Name {
+			from[n] = append(from[n], item.Name)
+		}
+	}
+
+	c := make(map[string]string)
+	for k, names := range from {
+		if _, ok := r.repos[k]; ok {
+			for _, n := range names {
+				c[n] = fmt.Sprintf("the repo:%s which is renamed from is still in the repo file", k)
+			}
+			continue
+		}
+
+		if len(names) > 1 {
+			for _, n := range names {
+				c[n] = fmt.Sprintf("repos:%s are all renamed from the repo:%s", strings.Join(names, ", "), k)
+			}
+		}
+	}
+
+	r.renameConflicts = c
 }
 
 type Repository struct {
 	Name              string       `json:"name" required:"true"`
 	Type              string       `json:"type" required:"true"`
+	Status            string       `json:"status,omitempty"`
 	RenameFrom        string       `json:"rename_from,omitempty"`
+	TransferFrom      string       `json:"transfer_from,omitempty"`
+	ImportURL         string       `json:"import_url,omitempty"`
+	ForkFrom          string       `json:"fork_from,omitempty"`
 	Description       string       `json:"description,omitempty"`
 	Commentable       bool         `json:"commentable,omitempty"`
 	ProtectedBranches []string     `json:"protected_branches,omitempty"`
 	Branches          []RepoBranch `json:"branches,omitempty"`
 
 	RepoMember
+	Freeze
 }
 
 func (r *Repository) IsPrivate() bool {
 	return r.Type == "private"
 }
 
+// GetStatus returns the lifecycle status of repo. Default to active.
+func (r *Repository) GetStatus() string {
+	if r.Status == "" {
+		return RepoStatusActive
+	}
+
+	return r.Status
+}
+
+// GetTransferFrom returns the org and repo which the repo is transferred from.
+func (r *Repository) GetTransferFrom() (string, string) {
+	return splitOrgRepo(r.TransferFrom)
+}
+
+// GetForkFrom returns the org and repo which the repo is forked from.
+func (r *Repository) GetForkFrom() (string, string) {
+	return splitOrgRepo(r.ForkFrom)
+}
+
+// HasUpstream checks whether the repo is created by forking or importing from an upstream.
+func (r *Repository) HasUpstream() bool {
+	return r.ForkFrom != "" || r.ImportURL != ""
+}
+
+func splitOrgRepo(s string) (string, string) {
+	if a := strings.Split(s, "/"); len(a) == 2 {
+		return a[0], a[1]
+	}
+
+	return "", ""
+}
+
 func (r *Repository) validate() error {
 	if r.Name == "" {
 		return fmt.Errorf("missing repo name")
@@ -96,6 +188,36 @@ func (r *Repository) validate() error {
 		return fmt.Errorf("missing repo type")
 	}
 
+	switch r.Status {
+	case "", RepoStatusIncubating, RepoStatusActive, RepoStatusDeprecated, RepoStatusArchived:
+	default:
+		return fmt.Errorf("unknown status:%s", r.Status)
+	}
+
+	if err := r.Freeze.validate(); err != nil {
+		return err
+	}
+
+	if r.TransferFrom != "" {
+		if org, repo := r.GetTransferFrom(); org == "" || repo == "" {
+			return fmt.Errorf("transfer_from must be in the format of org/repo")
+		}
+
+		if r.RenameFrom != "" {
+			return fmt.Errorf("can't set both rename_from and transfer_from")
+		}
+	}
+
+	if r.ForkFrom != "" {
+		if org, repo := r.GetForkFrom(); org == "" || repo == "" {
+			return fmt.Errorf("fork_from must be in the format of org/repo")
+		}
+
+		if r.ImportURL != "" {
+			return fmt.Errorf("can't set both fork_from and import_url")
+		}
+	}
+
 	for i := range r.Branches {
 		if err := r.Branches[i].validate(); err != nil {
 			return fmt.Errorf("validate %d branch, err:%s", i, err)
@@ -118,6 +240,48 @@ func (r *Repository) validate() error {
 	return nil
 }
 
+// Freeze stops the bot from changing the repos temporarily, such as during
+// an incident or a migration. The repos are still checked and reported.
+type Freeze struct {
+	// Managed is false means the repos are not managed by the bot. Default to true.
+	Managed *bool `json:"managed,omitempty"`
+
+	// FrozenUntil is the date, such as 2006-01-02, or the time in RFC3339
+	// before which the repos will not be changed.
+	FrozenUntil string `json:"frozen_until,omitempty"`
+
+	frozenUntil time.Time `json:"-"`
+}
+
+func (f *Freeze) validate() error {
+	if f.FrozenUntil == "" {
+		return nil
+	}
+
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, f.FrozenUntil); err == nil {
+			f.frozenUntil = t
+			return nil
+		}
+	}
+
+	return fmt.Errorf("frozen_until must be a date like 2006-01-02 or a time in RFC3339")
+}
+
+// GetFrozenReason returns why the repos can't be changed at the time t.
+// It returns empty string if they can be changed.
+func (f *Freeze) GetFrozenReason(t time.Time) string {
+	if f.Managed != nil && !*f.Managed {
+		return "not managed"
+	}
+
+	if !f.frozenUntil.IsZero() && t.Before(f.frozenUntil) {
+		return "frozen until " + f.FrozenUntil
+	}
+
+	return ""
+}
+
 type RepoMember struct {
 	Viewers    []string `json:"viewers,omitempty"`
 	Managers   []string `json:"managers,omitempty"`
@@ -168,6 +332,8 @@ type Sig struct {
 	Name         string   `json:"name" required:"true"`
 	Repositories []string `json:"repositories,omitempty"`
 
+	Freeze
+
 	repos map[string][]string `json:"-"`
 }
 
@@ -184,6 +350,10 @@ func (s *Sig) validate() error {
 		return fmt.Errorf("missing sig name")
 	}
 
+	if err := s.Freeze.validate(); err != nil {
+		return err
+	}
+
 	s.convert()
 	return nil
 }
@@ -209,9 +379,48 @@ func (s *Sig) convert() {
 
 type RepoOwners struct {
 	Maintainers []string `json:"maintainers,omitempty"`
-	all         []string `json:"-"`
+	Committers  []string `json:"committers,omitempty"`
+
+	// Repositories are the extra owners of the specified repos.
+	Repositories []OwnersOfRepos `json:"repositories,omitempty"`
+
+	all []string `json:"-"`
+}
+
+// OwnersOfRepos are the extra owners of the repos which match one of Repos.
+type OwnersOfRepos struct {
+	// Repos are the repo names or the glob patterns of them, such as kernel-*.
+	Repos       []string `json:"repos" required:"true"`
+	Maintainers []string `json:"maintainers,omitempty"`
+	Committers  []string `json:"committers,omitempty"`
 }
 
+func (o *OwnersOfRepos) isMatched(repo string) bool {
+	for _, p := range o.Repos {
+		if b, _ := path.Match(p, repo); b {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EffectiveOwners are the owners of a repo. The one who is both
+// maintainer and committer is only treated as maintainer.
+type EffectiveOwners struct {
+	Maintainers []string
+	Committers  []string
+}
+
+// GetOwners returns all the owners.
+func (e *EffectiveOwners) GetOwners() []string {
+	r := make([]string, 0, len(e.Maintainers)+len(e.Committers))
+	r = append(r, e.Maintainers...)
+
+	return append(r, e.Committers...)
+}
+
+// GetOwners returns all the maintainers and committers of sig, excluding the ones of specified repos.
 func (r *RepoOwners) GetOwners() []string {
 	if r == nil {
 		return nil
@@ -220,22 +429,79 @@ func (r *RepoOwners) GetOwners() []string {
 	return r.all
 }
 
+// GetMaintainers returns the maintainers of sig, excluding the ones of specified repos.
+func (r *RepoOwners) GetMaintainers() []string {
+	if r == nil {
+		return nil
+	}
+
+	return toLower(r.Maintainers)
+}
+
+// GetOwnersOfRepo returns the effective owners of the repo.
+func (r *RepoOwners) GetOwnersOfRepo(repo string) EffectiveOwners {
+	if r == nil {
+		return EffectiveOwners{}
+	}
+
+	m := sets.NewString(toLower(r.Maintainers)...)
+	c := sets.NewString(toLower(r.Committers)...)
+
+	for i := range r.Repositories {
+		if item := &r.Repositories[i]; item.isMatched(repo) {
+			m.Insert(toLower(item.Maintainers)...)
+			c.Insert(toLower(item.Committers)...)
+		}
+	}
+
+	return EffectiveOwners{
+		Maintainers: m.List(),
+		Committers:  c.Difference(m).List(),
+	}
+}
+
 func (r *RepoOwners) Validate() error {
 	if r == nil {
 		return fmt.Errorf("empty repo owners")
 	}
 
+	for i := range r.Repositories {
+		if err := r.Repositories[i].validate(); err != nil {
+			return fmt.Errorf("validate %d repositories, err:%s", i, err.Error())
+		}
+	}
+
 	r.convert()
 
 	return nil
 }
 
 func (r *RepoOwners) convert() {
-	o := make([]string, len(r.Maintainers))
+	m := sets.NewString(toLower(r.Maintainers)...)
+	c := sets.NewString(toLower(r.Committers)...)
+
+	r.all = append(m.List(), c.Difference(m).List()...)
+}
+
+func (o *OwnersOfRepos) validate() error {
+	if len(o.Repos) == 0 {
+		return fmt.Errorf("missing repos")
+	}
+
+	for _, p := range o.Repos {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid repo pattern:%s", p)
+		}
+	}
+
+	return nil
+}
 
-	for i, item := range r.Maintainers {
-		o[i] = strings.ToLower(item)
+func toLower(v []string) []string {
+	r := make([]string, len(v))
+	for i := range v {
+		r[i] = strings.ToLower(v[i])
 	}
 
-	r.all = o
+	return r
 }
@@ -0,0 +1,76 @@
+package community
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetOwnersOfRepo(t *testing.T) {
+	owners := &RepoOwners{
+		Maintainers: []string{"Alice"},
+		Committers:  []string{"bob", "alice"},
+		Repositories: []OwnersOfRepos{
+			{
+				Repos:       []string{"kernel-*"},
+				Maintainers: []string{"carol"},
+				Committers:  []string{"Bob", "dave"},
+			},
+			{
+				Repos:      []string{"kernel-doc"},
+				Committers: []string{"erin", "carol"},
+			},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		owners *RepoOwners
+		repo   string
+		expect EffectiveOwners
+	}{
+		{
+			name:   "no owners",
+			repo:   "kernel",
+			expect: EffectiveOwners{},
+		},
+		{
+			name:   "the maintainer is not a committer",
+			owners: owners,
+			repo:   "kernel",
+			expect: EffectiveOwners{
+				Maintainers: []string{"alice"},
+				Committers:  []string{"bob"},
+			},
+		},
+		{
+			name:   "the owners of matched repos are added",
+			owners: owners,
+			repo:   "kernel-doc",
+			expect: EffectiveOwners{
+				Maintainers: []string{"alice", "carol"},
+				Committers:  []string{"bob", "dave", "erin"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v := c.owners.GetOwnersOfRepo(c.repo)
+			if !reflect.DeepEqual(v, c.expect) {
+				t.Errorf("expect %+v, got %+v", c.expect, v)
+			}
+		})
+	}
+}
+
+func TestEffectiveOwnersGetOwners(t *testing.T) {
+	e := EffectiveOwners{
+		Maintainers: []string{"alice"},
+		Committers:  []string{"bob", "carol"},
+	}
+
+	expect := []string{"alice", "bob", "carol"}
+	if v := e.GetOwners(); !reflect.DeepEqual(v, expect) {
+		t.Errorf("expect %v, got %v", expect, v)
+	}
+}
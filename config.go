@@ -3,10 +3,15 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
+	"regexp"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/huaweicloud/golangsdk"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type configuration struct {
@@ -22,6 +27,11 @@ func (c *configuration) Validate() error {
 }
 
 func (c *configuration) SetDefault() {
+	if c == nil {
+		return
+	}
+
+	c.Config.setDefault()
 }
 
 type repoBranch struct {
@@ -42,6 +52,15 @@ type watchingFiles struct {
 
 	// SigDir is the directory which includes all the sigs. For example: sig
 	SigDir string `json:"sig_dir" required:"true"`
+
+	// PeerRepoFilePaths are the paths to repo files of other orgs which may transfer
+	// repos from this org. The repo being transferred out will not be treated as
+	// a deleted one. For example: repository/src-openeuler.yaml
+	PeerRepoFilePaths []string `json:"peer_repo_file_paths,omitempty"`
+
+	// IdentityFilePath is the path to the file which maps the emails or alternate ids
+	// of users to the gitee logins. For example: sig/identities.yaml
+	IdentityFilePath string `json:"identity_file_path,omitempty"`
 }
 
 func (w *watchingFiles) validate() error {
@@ -60,9 +79,39 @@ type obsMetaProject struct {
 	// ProjectFileName is the file name of new project
 	ProjectFileName string `json:"project_file_name" required:"true"`
 
-	// ProjectTemplatePath is the template file path which describes the new project
-	ProjectTemplatePath string `json:"project_template_path" required:"true"`
-	projectTemplate     string `json:"-"`
+	// ProjectTemplatePath is the template file path which describes the new project.
+	// It is a template of Go text/template which can reference the fields of repo,
+	// such as {{.Name}}, {{.Type}}, {{.Sig}} and {{.Branch}}. The legacy placeholder
+	// #projectname# is still supported and it equals to {{.Name}}.
+	ProjectTemplatePath string             `json:"project_template_path" required:"true"`
+	projectTemplate     *template.Template `json:"-"`
+
+	// RemoveProjectOfRemovedRepo means the project file will be removed
+	// when the repo is removed from the repo file.
+	RemoveProjectOfRemovedRepo bool `json:"remove_project_of_removed_repo,omitempty"`
+
+	// SyncProject means the project files of all the repos will be kept same as the ones
+	// generated by the template. The missing ones will be created and the different ones
+	// will be updated, such as when the template changes.
+	SyncProject bool `json:"sync_project,omitempty"`
+
+	// CommitByPR means the changes of project files in a check will be committed to
+	// a branch of bot and submitted by a single pull request to the Branch above,
+	// instead of being committed to it directly.
+	CommitByPR bool `json:"commit_by_pr,omitempty"`
+
+	// PRBranchPrefix is the prefix of branch which the changes will be committed to.
+	// The branch is created in the obs repo. Default to repo-watcher-obs-meta.
+	PRBranchPrefix string `json:"pr_branch_prefix,omitempty"`
+
+	// BranchProjects are the projects which are created for the branches of repo.
+	BranchProjects []obsBranchProject `json:"branch_projects,omitempty"`
+}
+
+func (o *obsMetaProject) setDefault() {
+	if o.PRBranchPrefix == "" {
+		o.PRBranchPrefix = "repo-watcher-obs-meta"
+	}
 }
 
 func (o *obsMetaProject) validate() error {
@@ -70,15 +119,90 @@ func (o *obsMetaProject) validate() error {
 		return err
 	}
 
-	t, err := newTemplate(o.ProjectTemplatePath)
+	t, err := parseProjectTemplate(o.ProjectTemplatePath)
 	if err != nil {
 		return err
 	}
 	o.projectTemplate = t
 
+	for i := range o.BranchProjects {
+		if err := o.BranchProjects[i].validate(o); err != nil {
+			return fmt.Errorf("validate %d branch project, err:%s", i, err.Error())
+		}
+	}
+
 	return nil
 }
 
+func parseProjectTemplate(p string) (*template.Template, error) {
+	v, err := newTemplate(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTemplate(path.Base(p), strings.ReplaceAll(v, "#projectname#", "{{.Name}}"))
+}
+
+// obsBranchProject describes the project which is created for each branch matching the pattern.
+type obsBranchProject struct {
+	// BranchPattern is the regular expression of branch name.
+	BranchPattern string `json:"branch_pattern" required:"true"`
+
+	// ProjectDir is the directory of project. It is a template which
+	// can reference the name of repo and branch, such as openEuler-{{.Branch}}.
+	ProjectDir string `json:"project_dir" required:"true"`
+
+	// ProjectFileName is the file name of project. Default to the one of obs_meta_project.
+	ProjectFileName string `json:"project_file_name,omitempty"`
+
+	// ProjectTemplatePath is the template file path which describes the project.
+	// Default to the one of obs_meta_project.
+	ProjectTemplatePath string `json:"project_template_path,omitempty"`
+
+	branchRegexp    *regexp.Regexp     `json:"-"`
+	dirTemplate     *template.Template `json:"-"`
+	projectTemplate *template.Template `json:"-"`
+}
+
+func (o *obsBranchProject) validate(parent *obsMetaProject) (err error) {
+	if o.branchRegexp, err = regexp.Compile(o.BranchPattern); err != nil {
+		return
+	}
+
+	if o.dirTemplate, err = parseTemplate(o.ProjectDir, o.ProjectDir); err != nil {
+		return
+	}
+
+	if o.ProjectFileName == "" {
+		o.ProjectFileName = parent.ProjectFileName
+	}
+
+	if o.ProjectTemplatePath == "" {
+		o.projectTemplate = parent.projectTemplate
+	} else {
+		o.projectTemplate, err = parseProjectTemplate(o.ProjectTemplatePath)
+	}
+
+	return
+}
+
+func (o *obsBranchProject) isMatched(branch string) bool {
+	return o.branchRegexp.MatchString(branch)
+}
+
+func (o *obsBranchProject) genProjectFilePath(data *repoTemplateData) (string, error) {
+	dir, err := execTemplate(o.dirTemplate, data)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, data.Name, o.ProjectFileName), nil
+}
+
+func (o *obsBranchProject) genProjectFileContent(data *repoTemplateData) (string, error) {
+	return execTemplate(o.projectTemplate, data)
+}
+
 func newTemplate(path string) (string, error) {
 	v, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -94,8 +218,811 @@ func (o *obsMetaProject) genProjectFilePath(p string) string {
 	return path.Join(o.ProjectDir, p, o.ProjectFileName)
 }
 
-func (o *obsMetaProject) genProjectFileContent(p string) (string, error) {
-	return strings.Replace(o.projectTemplate, "#projectname#", p, 1), nil
+func (o *obsMetaProject) genProjectFileContent(data *repoTemplateData) (string, error) {
+	return execTemplate(o.projectTemplate, data)
+}
+
+// leaderElection makes sure only one of the replicas watches the repos.
+// The others keep their caches warm and take over when the leader fails.
+type leaderElection struct {
+	Enable bool `json:"enable,omitempty"`
+
+	// Identity is the unique name of replica. Default to the hostname.
+	Identity string `json:"identity,omitempty"`
+
+	// LockType is the type of lock. Only "file" is supported now.
+	LockType string `json:"lock_type,omitempty"`
+
+	// LockFile is the path of lock file which must be shared by all the replicas.
+	LockFile string `json:"lock_file,omitempty"`
+
+	// LeaseDuration is the duration that the standby replicas will wait
+	// since they observed the last renewing of leader before taking over.
+	// It is measured by the local clock of each replica. The unit is second.
+	LeaseDuration int `json:"lease_duration,omitempty"`
+
+	// RenewDeadline is the duration that the leader will retry renewing
+	// before giving up the leadership. The unit is second.
+	RenewDeadline int `json:"renew_deadline,omitempty"`
+
+	// RetryPeriod is the interval of acquiring or renewing the leadership.
+	// The unit is second.
+	RetryPeriod int `json:"retry_period,omitempty"`
+
+	// WarmUpInterval is the interval of refreshing caches for the standby
+	// replicas. The unit is minute.
+	WarmUpInterval int `json:"warm_up_interval,omitempty"`
+}
+
+func (l *leaderElection) setDefault() {
+	if l.Identity == "" {
+		if v, err := os.Hostname(); err == nil {
+			l.Identity = v
+		}
+	}
+
+	if l.LockType == "" {
+		l.LockType = lockTypeFile
+	}
+
+	if l.LeaseDuration <= 0 {
+		l.LeaseDuration = 15
+	}
+
+	if l.RenewDeadline <= 0 {
+		l.RenewDeadline = 10
+	}
+
+	if l.RetryPeriod <= 0 {
+		l.RetryPeriod = 2
+	}
+
+	if l.WarmUpInterval <= 0 {
+		l.WarmUpInterval = 5
+	}
+}
+
+func (l *leaderElection) validate() error {
+	if !l.Enable {
+		return nil
+	}
+
+	if l.Identity == "" {
+		return fmt.Errorf("missing identity of leader election")
+	}
+
+	if l.LockType != lockTypeFile {
+		return fmt.Errorf("unsupported lock type:%s", l.LockType)
+	}
+
+	if l.LockFile == "" {
+		return fmt.Errorf("missing lock file of leader election")
+	}
+
+	if l.RenewDeadline >= l.LeaseDuration {
+		return fmt.Errorf("renew_deadline must be less than lease_duration")
+	}
+
+	if l.RetryPeriod >= l.RenewDeadline {
+		return fmt.Errorf("retry_period must be less than renew_deadline")
+	}
+
+	return nil
+}
+
+func (l *leaderElection) leaseDuration() time.Duration {
+	return time.Duration(l.LeaseDuration) * time.Second
+}
+
+func (l *leaderElection) renewDeadline() time.Duration {
+	return time.Duration(l.RenewDeadline) * time.Second
+}
+
+func (l *leaderElection) retryPeriod() time.Duration {
+	return time.Duration(l.RetryPeriod) * time.Second
+}
+
+func (l *leaderElection) warmUpInterval() time.Duration {
+	return time.Duration(l.WarmUpInterval) * time.Minute
+}
+
+const (
+	hookTypeOBSMetaProject = "obs_meta_project"
+	hookTypeWriteFiles     = "write_files"
+	hookTypeSeedFiles      = "seed_files"
+	hookTypeInitLabels     = "init_labels"
+	hookTypeAddWebhooks    = "add_webhooks"
+)
+
+// repoHook is the action which will be done after a repo is created or renamed.
+type repoHook struct {
+	// Name is the unique name of hook which is used to report the result.
+	Name string `json:"name" required:"true"`
+
+	// Type is the type of hook. It is one of obs_meta_project,
+	// write_files, seed_files, init_labels and add_webhooks.
+	Type string `json:"type" required:"true"`
+
+	Enable bool `json:"enable,omitempty"`
+
+	// Order decides the sequence of the hooks. The smaller one runs earlier.
+	Order int `json:"order,omitempty"`
+
+	// OnRename means the hook will be invoked after a repo is renamed too.
+	OnRename bool `json:"on_rename,omitempty"`
+
+	// Files are the files which will be written by write_files or seed_files.
+	Files []hookFile `json:"files,omitempty"`
+
+	// Labels are the labels which will be created by init_labels.
+	Labels []repoLabel `json:"labels,omitempty"`
+
+	// Webhooks are the webhooks which will be added by add_webhooks.
+	Webhooks []hookWebhook `json:"webhooks,omitempty"`
+}
+
+func (h *repoHook) validate() error {
+	if h.Name == "" {
+		return fmt.Errorf("missing hook name")
+	}
+
+	switch h.Type {
+	case hookTypeOBSMetaProject:
+	case hookTypeWriteFiles, hookTypeSeedFiles:
+		if len(h.Files) == 0 {
+			return fmt.Errorf("missing files of hook:%s", h.Name)
+		}
+
+		for i := range h.Files {
+			if err := h.Files[i].validate(h.Type == hookTypeWriteFiles); err != nil {
+				return fmt.Errorf("validate %d file of hook:%s, err:%s", i, h.Name, err.Error())
+			}
+		}
+	case hookTypeInitLabels:
+		if len(h.Labels) == 0 {
+			return fmt.Errorf("missing labels of hook:%s", h.Name)
+		}
+
+		for i := range h.Labels {
+			if h.Labels[i].Name == "" {
+				return fmt.Errorf("missing name of %d label of hook:%s", i, h.Name)
+			}
+		}
+	case hookTypeAddWebhooks:
+		if len(h.Webhooks) == 0 {
+			return fmt.Errorf("missing webhooks of hook:%s", h.Name)
+		}
+
+		for i := range h.Webhooks {
+			if err := h.Webhooks[i].validate(); err != nil {
+				return fmt.Errorf("validate %d webhook of hook:%s, err:%s", i, h.Name, err.Error())
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type:%s of hook:%s", h.Type, h.Name)
+	}
+
+	return nil
+}
+
+// hookFile is the file which will be written after a repo is created.
+// Both the path and the content are templates which can reference
+// the fields of the new repo, such as {{.Name}}.
+type hookFile struct {
+	// Target is the repo and branch the file will be written to.
+	// It is only used by write_files. seed_files always writes to the new repo.
+	Target repoBranch `json:"target,omitempty"`
+
+	// Path is the path of file.
+	Path string `json:"path" required:"true"`
+
+	// TemplatePath is the path of template file which describes the content.
+	TemplatePath string `json:"template_path" required:"true"`
+
+	CommitMessage string `json:"commit_message,omitempty"`
+
+	pathTemplate    *template.Template `json:"-"`
+	contentTemplate *template.Template `json:"-"`
+}
+
+func (f *hookFile) validate(needTarget bool) (err error) {
+	if needTarget {
+		if t := &f.Target; t.Org == "" || t.Repo == "" || t.Branch == "" {
+			return fmt.Errorf("missing target")
+		}
+	}
+
+	if f.Path == "" || f.TemplatePath == "" {
+		return fmt.Errorf("missing path or template_path")
+	}
+
+	if f.pathTemplate, err = parseTemplate(f.Path, f.Path); err != nil {
+		return
+	}
+
+	f.contentTemplate, err = parseTemplateFile(f.TemplatePath)
+
+	return
+}
+
+type hookWebhook struct {
+	URL string `json:"url" required:"true"`
+
+	// PasswordPath is the path of file which includes the password of webhook.
+	PasswordPath string `json:"password_path,omitempty"`
+
+	// Events are the events which will trigger the webhook.
+	// It can be push, tag_push, issues, note and merge_requests.
+	Events []string `json:"events,omitempty"`
+
+	password string `json:"-"`
+}
+
+func (w *hookWebhook) validate() error {
+	if w.URL == "" {
+		return fmt.Errorf("missing url")
+	}
+
+	if w.PasswordPath != "" {
+		v, err := ioutil.ReadFile(w.PasswordPath)
+		if err != nil {
+			return err
+		}
+		w.password = strings.TrimSpace(string(v))
+	}
+
+	all := sets.NewString("push", "tag_push", "issues", "note", "merge_requests")
+	for _, e := range w.Events {
+		if !all.Has(e) {
+			return fmt.Errorf("unknown event:%s", e)
+		}
+	}
+
+	return nil
+}
+
+func (w *hookWebhook) toRepoWebhook() repoWebhook {
+	events := sets.NewString(w.Events...)
+
+	return repoWebhook{
+		URL:                 w.URL,
+		Password:            w.password,
+		PushEvents:          events.Has("push"),
+		TagPushEvents:       events.Has("tag_push"),
+		IssuesEvents:        events.Has("issues"),
+		NoteEvents:          events.Has("note"),
+		MergeRequestsEvents: events.Has("merge_requests"),
+	}
+}
+
+const (
+	ownersFileFormatOwners     = "owners"
+	ownersFileFormatCodeOwners = "codeowners"
+)
+
+// ownersFile is the file on the master branch of each repo which records
+// the owners of repo, so that the other bots can find the reviewers.
+type ownersFile struct {
+	Enable bool `json:"enable,omitempty"`
+
+	// Format is the format of file. It can be owners or codeowners. Default to owners.
+	Format string `json:"format,omitempty"`
+
+	// Path is the path of file. Default to OWNERS for owners and .gitee/CODEOWNERS for codeowners.
+	Path string `json:"path,omitempty"`
+
+	CommitMessage string `json:"commit_message,omitempty"`
+}
+
+func (o *ownersFile) setDefault() {
+	if o.Format == "" {
+		o.Format = ownersFileFormatOwners
+	}
+
+	if o.Path == "" {
+		if o.Format == ownersFileFormatCodeOwners {
+			o.Path = ".gitee/CODEOWNERS"
+		} else {
+			o.Path = "OWNERS"
+		}
+	}
+
+	if o.CommitMessage == "" {
+		o.CommitMessage = "update the owners of repo"
+	}
+}
+
+func (o *ownersFile) validate() error {
+	if !o.Enable {
+		return nil
+	}
+
+	if o.Format != ownersFileFormatOwners && o.Format != ownersFileFormatCodeOwners {
+		return fmt.Errorf("unknown format of owners file:%s", o.Format)
+	}
+
+	return nil
+}
+
+// sigTeam decides how to sync each sig to a team whose members are the
+// maintainers of sig and which has the permission of repos of sig.
+type sigTeam struct {
+	// Enable turns on syncing the teams. It is off by default, because the team
+	// apis are not in the public document of gitee api v5 and may change.
+	Enable bool `json:"enable,omitempty"`
+
+	// NamePrefix is the prefix of team name. Default to sig-.
+	NamePrefix string `json:"name_prefix,omitempty"`
+
+	// Permission is the permission of team on the repos of sig. It can be pull, push or admin.
+	// Default to push.
+	Permission string `json:"permission,omitempty"`
+
+	// ResyncInterval is the minutes after which the members and repos of team are
+	// listed again, so that the changes made by others are found. Default to 60.
+	ResyncInterval int `json:"resync_interval,omitempty"`
+}
+
+func (s *sigTeam) setDefault() {
+	if s.NamePrefix == "" {
+		s.NamePrefix = "sig-"
+	}
+
+	if s.Permission == "" {
+		s.Permission = "push"
+	}
+
+	if s.ResyncInterval <= 0 {
+		s.ResyncInterval = 60
+	}
+}
+
+func (s *sigTeam) validate() error {
+	if !s.Enable {
+		return nil
+	}
+
+	if !sets.NewString("pull", "push", "admin").Has(s.Permission) {
+		return fmt.Errorf("unknown permission of sig team:%s", s.Permission)
+	}
+
+	return nil
+}
+
+func (s *sigTeam) resyncInterval() time.Duration {
+	return time.Duration(s.ResyncInterval) * time.Minute
+}
+
+func (s *sigTeam) teamName(sig string) string {
+	return s.NamePrefix + sig
+}
+
+const (
+	notifyChannelEmail        = "email"
+	notifyChannelIssueComment = "issue_comment"
+	notifyChannelWebhook      = "webhook"
+)
+
+// notification decides how to send the changes of repos which are
+// grouped by sig at the end of each check.
+type notification struct {
+	Enable bool `json:"enable,omitempty"`
+
+	// TemplatePath is the path of Go text/template of notification content
+	// which can reference the fields of notificationData, such as {{.Sig}}.
+	// The builtin template will be used if it is empty.
+	TemplatePath string `json:"template_path,omitempty"`
+
+	Channels []notifyChannel `json:"channels,omitempty"`
+
+	contentTemplate *template.Template `json:"-"`
+}
+
+func (n *notification) validate() error {
+	if !n.Enable {
+		return nil
+	}
+
+	if len(n.Channels) == 0 {
+		return fmt.Errorf("missing notification channels")
+	}
+
+	names := sets.NewString()
+	for i := range n.Channels {
+		item := &n.Channels[i]
+
+		if err := item.validate(); err != nil {
+			return fmt.Errorf("validate %d channel, err:%s", i, err.Error())
+		}
+
+		if names.Has(item.Name) {
+			return fmt.Errorf("duplicate channel:%s", item.Name)
+		}
+		names.Insert(item.Name)
+	}
+
+	t, err := parseNotificationTemplate(n.TemplatePath)
+	if err != nil {
+		return err
+	}
+	n.contentTemplate = t
+
+	return nil
+}
+
+type notifyChannel struct {
+	Name string `json:"name" required:"true"`
+
+	// Type is the type of channel. It can be email, issue_comment and webhook.
+	Type string `json:"type" required:"true"`
+
+	// Sigs are the sigs whose changes will be sent through the channel.
+	// All the changes will be sent if it is empty.
+	Sigs []string `json:"sigs,omitempty"`
+
+	Email   *emailChannel   `json:"email,omitempty"`
+	Issue   *issueChannel   `json:"issue,omitempty"`
+	Webhook *webhookChannel `json:"webhook,omitempty"`
+}
+
+func (c *notifyChannel) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("missing channel name")
+	}
+
+	switch c.Type {
+	case notifyChannelEmail:
+		if c.Email == nil {
+			return fmt.Errorf("missing email of channel:%s", c.Name)
+		}
+		return c.Email.validate()
+
+	case notifyChannelIssueComment:
+		if c.Issue == nil {
+			return fmt.Errorf("missing issue of channel:%s", c.Name)
+		}
+		_, err := golangsdk.BuildRequestBody(c.Issue, "")
+		return err
+
+	case notifyChannelWebhook:
+		if c.Webhook == nil || c.Webhook.URL == "" {
+			return fmt.Errorf("missing webhook url of channel:%s", c.Name)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown type of channel:%s", c.Type)
+	}
+}
+
+func (c *notifyChannel) isRouted(sig string) bool {
+	if len(c.Sigs) == 0 {
+		return true
+	}
+
+	for _, v := range c.Sigs {
+		if v == sig {
+			return true
+		}
+	}
+
+	return false
+}
+
+type emailChannel struct {
+	Host string `json:"host" required:"true"`
+	Port int    `json:"port" required:"true"`
+	From string `json:"from" required:"true"`
+
+	Username string `json:"username,omitempty"`
+
+	// PasswordPath is the path of file which includes the password of smtp server.
+	PasswordPath string `json:"password_path,omitempty"`
+
+	// To are the recipients of all the changes.
+	To []string `json:"to,omitempty"`
+
+	// SigTo are the recipients of the changes of each sig, such as the mailing list of sig.
+	SigTo map[string][]string `json:"sig_to,omitempty"`
+
+	password string `json:"-"`
+}
+
+func (e *emailChannel) validate() error {
+	if e.Host == "" || e.Port <= 0 || e.From == "" {
+		return fmt.Errorf("missing host, port or from of email")
+	}
+
+	if e.PasswordPath != "" {
+		v, err := ioutil.ReadFile(e.PasswordPath)
+		if err != nil {
+			return err
+		}
+		e.password = strings.TrimSpace(string(v))
+	}
+
+	return nil
+}
+
+// issueChannel is the issue which the changes will be commented on.
+type issueChannel struct {
+	Org    string `json:"org" required:"true"`
+	Repo   string `json:"repo" required:"true"`
+	Number string `json:"number" required:"true"`
+}
+
+type webhookChannel struct {
+	URL string `json:"url" required:"true"`
+}
+
+type unexpectedMembers struct {
+	// ReportOnly means the unexpected members will only be reported instead of being removed.
+	ReportOnly bool `json:"report_only,omitempty"`
+
+	// Allowlist are the members which will never be removed.
+	Allowlist memberAllowlist `json:"allowlist,omitempty"`
+}
+
+func (u *unexpectedMembers) validate() error {
+	return u.Allowlist.validate()
+}
+
+// memberAllowlist are the logins or the glob patterns of them, such as *-bot.
+type memberAllowlist struct {
+	Global []string `json:"global,omitempty"`
+
+	// ByOrg are the allowlists of each org.
+	ByOrg map[string][]string `json:"by_org,omitempty"`
+
+	// BySig are the allowlists of each sig.
+	BySig map[string][]string `json:"by_sig,omitempty"`
+
+	// ByRepo are the allowlists of each repo in the format of org/repo.
+	ByRepo map[string][]string `json:"by_repo,omitempty"`
+}
+
+func (m *memberAllowlist) validate() error {
+	check := func(v []string) error {
+		for _, p := range v {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid member pattern:%s", p)
+			}
+		}
+		return nil
+	}
+
+	if err := check(m.Global); err != nil {
+		return err
+	}
+
+	for _, items := range []map[string][]string{m.ByOrg, m.BySig, m.ByRepo} {
+		for _, v := range items {
+			if err := check(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *memberAllowlist) has(org, sig, repo, login string) bool {
+	match := func(v []string) bool {
+		for _, p := range v {
+			if b, _ := path.Match(strings.ToLower(p), login); b {
+				return true
+			}
+		}
+		return false
+	}
+
+	if match(m.Global) || match(m.ByOrg[org]) || match(m.ByRepo[org+"/"+repo]) {
+		return true
+	}
+
+	return sig != "" && match(m.BySig[sig])
+}
+
+// repoTemplate decides which template repo will be used to seed the new repo.
+// The files of template repo are templates which can reference the fields
+// of the new repo, such as {{.Name}}. All the template repos are in the
+// format of org/repo and the empty one means not seeding.
+type repoTemplate struct {
+	// Default is the template repo for all the new repos.
+	Default string `json:"default,omitempty"`
+
+	// Branch is the branch of template repo. Default to master.
+	Branch string `json:"branch,omitempty"`
+
+	// ByType overrides the default one for the repos of the type.
+	ByType map[string]string `json:"by_type,omitempty"`
+
+	// BySig overrides the default one and the one by type for the repos of the sig.
+	BySig map[string]string `json:"by_sig,omitempty"`
+
+	// Suffix is the suffix of template files. Only the path and content of them are
+	// rendered with the data of repo, and the suffix is trimmed from the path.
+	// The other files are committed as they are. Default to .tmpl.
+	Suffix string `json:"suffix,omitempty"`
+
+	// PendingFile is the path of file which persists the new repos which have not been
+	// seeded completely. They are only kept in memory if unset, and will not be seeded
+	// again after restarting.
+	PendingFile string `json:"pending_file,omitempty"`
+}
+
+func (t *repoTemplate) setDefault() {
+	if t.Branch == "" {
+		t.Branch = "master"
+	}
+
+	if t.Suffix == "" {
+		t.Suffix = ".tmpl"
+	}
+}
+
+func (t *repoTemplate) validate() error {
+	check := func(v string) error {
+		if v == "" {
+			return nil
+		}
+
+		if a := strings.Split(v, "/"); len(a) != 2 || a[0] == "" || a[1] == "" {
+			return fmt.Errorf("invalid template repo:%s, it must be in the format of org/repo", v)
+		}
+		return nil
+	}
+
+	if err := check(t.Default); err != nil {
+		return err
+	}
+
+	for _, v := range t.ByType {
+		if err := check(v); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range t.BySig {
+		if err := check(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// get returns the org and repo of template repo for the new repo.
+func (t *repoTemplate) get(sig, repoType string) (string, string) {
+	v := t.Default
+
+	if s, ok := t.ByType[repoType]; ok {
+		v = s
+	}
+
+	if s, ok := t.BySig[sig]; ok && sig != "" {
+		v = s
+	}
+
+	if a := strings.Split(v, "/"); len(a) == 2 {
+		return a[0], a[1]
+	}
+
+	return "", ""
+}
+
+type workQueue struct {
+	// QPS is the max number of repos handled per second. 0 or unset means no limit.
+	QPS int `json:"qps,omitempty"`
+
+	// RetryBaseDelay is the delay before handling a failed repo again. It doubles
+	// at each failure until RetryMaxDelay. The unit is second. Default to 30.
+	RetryBaseDelay int `json:"retry_base_delay,omitempty"`
+
+	// RetryMaxDelay is the max delay before handling a failed repo again.
+	// The unit is second. Default to 600.
+	RetryMaxDelay int `json:"retry_max_delay,omitempty"`
+
+	// LaneShares are the shares of workers which handle the repos of each lane first.
+	LaneShares laneShares `json:"lane_shares,omitempty"`
+}
+
+// laneShares are the shares of workers of each lane. A worker handles the repos
+// of the other lanes when its own lane is empty. The default is 1:1:2.
+type laneShares struct {
+	// Create is the share of lane for creating, renaming and transferring repos.
+	Create int `json:"create,omitempty"`
+
+	// Security is the share of lane for removing the unexpected members.
+	Security int `json:"security,omitempty"`
+
+	// Routine is the share of lane for checking the other repos.
+	Routine int `json:"routine,omitempty"`
+}
+
+func (l *laneShares) setDefault() {
+	if l.Create == 0 && l.Security == 0 && l.Routine == 0 {
+		l.Create = 1
+		l.Security = 1
+		l.Routine = 2
+	}
+}
+
+func (l *laneShares) validate() error {
+	if l.Create < 0 || l.Security < 0 || l.Routine < 0 {
+		return fmt.Errorf("the share of lane must not be negative")
+	}
+
+	return nil
+}
+
+// workers returns the number of workers of each lane. The rest of workers
+// after dividing by the shares belong to the former lanes.
+func (l *laneShares) workers(total int) [laneCount]int {
+	shares := [laneCount]int{laneCreate: l.Create, laneSecurity: l.Security, laneRoutine: l.Routine}
+
+	sum := 0
+	for _, v := range shares {
+		sum += v
+	}
+
+	r := [laneCount]int{}
+	if sum == 0 {
+		return r
+	}
+
+	n := 0
+	for i, v := range shares {
+		r[i] = total * v / sum
+		n += r[i]
+	}
+
+	for i := 0; n < total; i = (i + 1) % laneCount {
+		if shares[i] > 0 {
+			r[i]++
+			n++
+		}
+	}
+
+	return r
+}
+
+func (w *workQueue) setDefault() {
+	if w.RetryBaseDelay <= 0 {
+		w.RetryBaseDelay = 30
+	}
+
+	if w.RetryMaxDelay <= 0 {
+		w.RetryMaxDelay = 600
+	}
+
+	w.LaneShares.setDefault()
+}
+
+func (w *workQueue) validate() error {
+	if w.QPS < 0 {
+		return fmt.Errorf("qps must not be negative")
+	}
+
+	if w.RetryMaxDelay < w.RetryBaseDelay {
+		return fmt.Errorf("retry_max_delay must not be less than retry_base_delay")
+	}
+
+	return w.LaneShares.validate()
+}
+
+// retryDelay returns the delay before retrying the repo which has failed n times before.
+func (w *workQueue) retryDelay(n int) time.Duration {
+	d := w.RetryBaseDelay
+	for i := 0; i < n && d < w.RetryMaxDelay; i++ {
+		d *= 2
+	}
+
+	if d > w.RetryMaxDelay {
+		d = w.RetryMaxDelay
+	}
+
+	return time.Duration(d) * time.Second
 }
 
 type botConfig struct {
@@ -104,14 +1031,110 @@ type botConfig struct {
 	// ConcurrentSize is the concurrent size for doing task
 	ConcurrentSize int `json:"concurrent_size" required:"true"`
 
+	// WorkQueue is the configuration of the queue of repos waiting to be handled.
+	WorkQueue workQueue `json:"work_queue,omitempty"`
+
 	// Interval is the one between repo checkes. 0 or unset means check repos consecutively.
 	// The unit is minute.
 	Interval int `json:"interval,omitempty"`
 
+	// ShutdownTimeout is the max time to wait for the running tasks to finish
+	// after receiving the exit signal. The tasks which are not finished in time
+	// will be cancelled and recorded as incomplete. The unit is second.
+	ShutdownTimeout int `json:"shutdown_timeout,omitempty"`
+
+	// RenameHistoryFile is the path of file which persists the history of renaming repos.
+	// The history is used to resolve the rename chain and it is only kept in memory if unset.
+	RenameHistoryFile string `json:"rename_history_file,omitempty"`
+
+	// StatusHistoryFile is the path of file which persists the lifecycle status applied to
+	// the repos and the branches protected for it. The history is only kept in memory if unset,
+	// and the status of all repos will be applied again after restarting.
+	StatusHistoryFile string `json:"status_history_file,omitempty"`
+
+	// CheckpointFile is the path of file which persists the commit of community repo checked
+	// at last. The repos removed while the bot is down are found by comparing with the repo
+	// file at the commit. They will be missed if unset. The results of handling the repos
+	// are persisted in it too, so that they are included by the report command.
+	CheckpointFile string `json:"checkpoint_file,omitempty"`
+
+	// InterruptedTasksFile is the path of file which persists the repos whose tasks are
+	// cancelled when exiting. They are handled first after restarting. The repos are
+	// only kept in memory if unset.
+	InterruptedTasksFile string `json:"interrupted_tasks_file,omitempty"`
+
+	// OwnersFile is the configuration of generating the owners file of each repo.
+	OwnersFile ownersFile `json:"owners_file,omitempty"`
+
+	// SigTeam is the configuration of syncing each sig to a team.
+	SigTeam sigTeam `json:"sig_team,omitempty"`
+
+	// UnexpectedMembers decides how to handle the members of repo which are not the owners.
+	UnexpectedMembers unexpectedMembers `json:"unexpected_members,omitempty"`
+
+	// Notification is the configuration of sending the changes of repos.
+	Notification notification `json:"notification,omitempty"`
+
+	// CommentOnCommunityPR means commenting the results of handling repos
+	// on the pull request of community repo which changes them.
+	CommentOnCommunityPR bool `json:"comment_on_community_pr,omitempty"`
+
+	// AdminAddress is the address of admin server which serves the report of repos,
+	// such as :8888. The server will not be started if it is empty. The server has no
+	// authentication, so the host defaults to 127.0.0.1 and it must be set explicitly,
+	// such as 0.0.0.0:8888, to serve on the other interfaces.
+	AdminAddress string `json:"admin_address,omitempty"`
+
+	// UserCacheTTL is the minutes that the result of validating user login is cached. Default to 60.
+	UserCacheTTL int `json:"user_cache_ttl,omitempty"`
+
+	// RepoTemplate is the template repo whose files will be committed to the new repos.
+	RepoTemplate repoTemplate `json:"repo_template,omitempty"`
+
+	// LeaderElection is the configuration of electing leader among replicas.
+	LeaderElection leaderElection `json:"leader_election,omitempty"`
+
 	// EnableCreatingOBSMetaProject is the switch of creating project in obs meta repo
 	EnableCreatingOBSMetaProject bool `json:"enable_creating_obs_meta_project,omitempty"`
 
 	OBSMetaProject obsMetaProject `json:"obs_meta_project"`
+
+	// Hooks are the actions which will be done after a repo is created or renamed.
+	Hooks []repoHook `json:"hooks,omitempty"`
+
+	// FailedHooksFile is the path of file which persists the hooks of each repo which
+	// failed or were not run. They are run again when the repo is handled next time,
+	// including after restarting. They are only kept in memory if unset.
+	FailedHooksFile string `json:"failed_hooks_file,omitempty"`
+}
+
+func (c *botConfig) setDefault() {
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = 60
+	}
+
+	if c.UserCacheTTL <= 0 {
+		c.UserCacheTTL = 60
+	}
+
+	if strings.HasPrefix(c.AdminAddress, ":") {
+		c.AdminAddress = "127.0.0.1" + c.AdminAddress
+	}
+
+	c.WorkQueue.setDefault()
+	c.LeaderElection.setDefault()
+	c.OBSMetaProject.setDefault()
+	c.RepoTemplate.setDefault()
+	c.OwnersFile.setDefault()
+	c.SigTeam.setDefault()
+}
+
+func (c *botConfig) shutdownTimeout() time.Duration {
+	return time.Duration(c.ShutdownTimeout) * time.Second
+}
+
+func (c *botConfig) userCacheTTL() time.Duration {
+	return time.Duration(c.UserCacheTTL) * time.Minute
 }
 
 func (c *botConfig) validate() error {
@@ -123,8 +1146,64 @@ func (c *botConfig) validate() error {
 		return fmt.Errorf("concurrent_size must be bigger than 0")
 	}
 
-	if c.EnableCreatingOBSMetaProject {
+	if err := c.WorkQueue.validate(); err != nil {
+		return err
+	}
+
+	if err := c.LeaderElection.validate(); err != nil {
+		return err
+	}
+
+	if err := c.RepoTemplate.validate(); err != nil {
+		return err
+	}
+
+	if err := c.OwnersFile.validate(); err != nil {
+		return err
+	}
+
+	if err := c.SigTeam.validate(); err != nil {
+		return err
+	}
+
+	if err := c.UnexpectedMembers.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Notification.validate(); err != nil {
+		return err
+	}
+
+	names := sets.NewString()
+	for i := range c.Hooks {
+		item := &c.Hooks[i]
+
+		if err := item.validate(); err != nil {
+			return fmt.Errorf("validate %d hook, err:%s", i, err.Error())
+		}
+
+		if names.Has(item.Name) {
+			return fmt.Errorf("validate %d hook, err:duplicate hook:%s", i, item.Name)
+		}
+		names.Insert(item.Name)
+	}
+
+	if c.needOBSMetaProject() {
 		return c.OBSMetaProject.validate()
 	}
 	return nil
 }
+
+func (c *botConfig) needOBSMetaProject() bool {
+	if c.EnableCreatingOBSMetaProject {
+		return true
+	}
+
+	for i := range c.Hooks {
+		if item := &c.Hooks[i]; item.Enable && item.Type == hookTypeOBSMetaProject {
+			return true
+		}
+	}
+
+	return false
+}
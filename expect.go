@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"path"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -18,7 +22,7 @@ type watchingFileObject interface {
 
 type watchingFile struct {
 	log      *logrus.Entry
-	loadFile func(string) (string, string, error)
+	loadFile func(context.Context, string) (string, string, error)
 
 	file string
 	sha  string
@@ -27,12 +31,12 @@ type watchingFile struct {
 
 type getSHAFunc func(string) string
 
-func (w *watchingFile) update(f getSHAFunc, newObject func() watchingFileObject) {
+func (w *watchingFile) update(ctx context.Context, f getSHAFunc, newObject func() watchingFileObject) {
 	if sha := f(w.file); sha == "" || sha == w.sha {
 		return
 	}
 
-	c, sha, err := w.loadFile(w.file)
+	c, sha, err := w.loadFile(ctx, w.file)
 	if err != nil {
 		w.log.Errorf("load file:%s, err:%s", w.file, err.Error())
 		return
@@ -57,8 +61,8 @@ type expectRepos struct {
 	wf watchingFile
 }
 
-func (e *expectRepos) refresh(f getSHAFunc) *community.Repos {
-	e.wf.update(f, func() watchingFileObject {
+func (e *expectRepos) refresh(ctx context.Context, f getSHAFunc) *community.Repos {
+	e.wf.update(ctx, f, func() watchingFileObject {
 		return new(community.Repos)
 	})
 
@@ -72,8 +76,8 @@ type orgSigs struct {
 	wf watchingFile
 }
 
-func (s *orgSigs) refresh(f getSHAFunc) *community.Sigs {
-	s.wf.update(f, func() watchingFileObject {
+func (s *orgSigs) refresh(ctx context.Context, f getSHAFunc) *community.Sigs {
+	s.wf.update(ctx, f, func() watchingFileObject {
 		return new(community.Sigs)
 	})
 
@@ -87,8 +91,8 @@ type expectSigOwners struct {
 	wf watchingFile
 }
 
-func (e *expectSigOwners) refresh(f getSHAFunc) *community.RepoOwners {
-	e.wf.update(f, func() watchingFileObject {
+func (e *expectSigOwners) refresh(ctx context.Context, f getSHAFunc) *community.RepoOwners {
+	e.wf.update(ctx, f, func() watchingFileObject {
 		return new(community.RepoOwners)
 	})
 
@@ -98,6 +102,32 @@ func (e *expectSigOwners) refresh(f getSHAFunc) *community.RepoOwners {
 	return nil
 }
 
+type expectIdentities struct {
+	wf watchingFile
+}
+
+func (e *expectIdentities) refresh(ctx context.Context, f getSHAFunc) *community.Identities {
+	e.wf.update(ctx, f, func() watchingFileObject {
+		return new(community.Identities)
+	})
+
+	if v, ok := e.wf.obj.(*community.Identities); ok {
+		return v
+	}
+	return nil
+}
+
+type expectSig struct {
+	maintainers []string
+	repos       []string
+
+	// frozenRepos are the repos of sig which can't be changed.
+	frozenRepos []string
+
+	// frozen is the reason why the sig can't be changed.
+	frozen string
+}
+
 type expectState struct {
 	log *logrus.Entry
 	cli iClient
@@ -107,12 +137,45 @@ type expectState struct {
 	repos     expectRepos
 	sigDir    string
 	sigOwners map[string]*expectSigOwners
+
+	identities expectIdentities
+	users      *userResolver
+
+	// commit is the commit of community repo which is checked. All the files are loaded
+	// at it if it is set, otherwise they are loaded at the head of branch.
+	commit string
+
+	// sigs are the maintainers and repos of each sig which is checked completely.
+	sigs map[string]expectSig
+
+	// sigFreezes are the freezes of all sigs.
+	sigFreezes map[string]*community.Freeze
+
+	// unresolvedOwners are the owners of each sig which can't be resolved to gitee logins.
+	unresolvedOwners map[string][]string
+
+	// peers are the repo files of other orgs which may transfer repos from this org.
+	peers []expectRepos
+
+	// transferredOut are the repos which are being transferred to other orgs.
+	// The value is the target in the format of org/repo.
+	transferredOut map[string]string
 }
 
-func (e *expectState) init(repoFilePath, sigFilePath, sigDir string) (string, error) {
+func (e *expectState) init(
+	ctx context.Context,
+	repoFilePath, sigFilePath, sigDir, identityFilePath string,
+	peerRepoFilePaths []string,
+) (string, error) {
 	e.repos = expectRepos{e.newWatchingFile(repoFilePath)}
+	e.identities = expectIdentities{e.newWatchingFile(identityFilePath)}
+
+	e.peers = make([]expectRepos, len(peerRepoFilePaths))
+	for i, p := range peerRepoFilePaths {
+		e.peers[i] = expectRepos{e.newWatchingFile(p)}
+	}
 
-	v := e.repos.refresh(func(string) string {
+	v := e.repos.refresh(ctx, func(string) string {
 		return "init"
 	})
 
@@ -127,13 +190,41 @@ func (e *expectState) init(repoFilePath, sigFilePath, sigDir string) (string, er
 	return org, nil
 }
 
+// repoChanges are the repos changed between two commits of community repo.
+type repoChanges struct {
+	from string
+	to   string
+
+	repoFile  string
+	lastRepos map[string]*community.Repository
+
+	// repos are the repos whose definitions are changed in the repo file.
+	repos []string
+
+	// owners are the repos whose owners are changed in each OWNERS file.
+	owners map[string][]string
+}
+
+func (c *repoChanges) isEmpty() bool {
+	return len(c.repos) == 0 && len(c.owners) == 0
+}
+
+// check checks all the expected repos. trackChanges is called with the repos
+// changed since last check before any repo is checked, if it is not nil.
 func (e *expectState) check(
+	ctx context.Context,
 	org string,
 	isStopped func() bool,
 	clearLocal func(func(string) bool),
-	checkRepo func(*community.Repository, []string, *logrus.Entry),
+	checkRepo func(repo *community.Repository, sig string, owners community.EffectiveOwners, log *logrus.Entry),
+	trackChanges func(*repoChanges),
 ) {
-	allFiles, err := e.listAllFilesOfRepo()
+	lastCommit := e.commit
+	if trackChanges != nil {
+		e.refreshCommit(ctx)
+	}
+
+	allFiles, err := e.listAllFilesOfRepo(ctx)
 	if err != nil {
 		e.log.Errorf("list all file, err:%s", err.Error())
 
@@ -144,7 +235,9 @@ func (e *expectState) check(
 		return allFiles[p]
 	}
 
-	allRepos := e.repos.refresh(getSHA)
+	lastRepos, _ := e.repos.wf.obj.(*community.Repos)
+
+	allRepos := e.repos.refresh(ctx, getSHA)
 	repoMap := allRepos.GetRepos()
 
 	if len(repoMap) == 0 {
@@ -153,20 +246,78 @@ func (e *expectState) check(
 		return
 	}
 
+	changes := repoChanges{
+		from:     lastCommit,
+		to:       e.commit,
+		repoFile: e.repos.wf.file,
+		owners:   make(map[string][]string),
+	}
+	if lastRepos != nil && lastRepos != allRepos {
+		changes.lastRepos = lastRepos.GetRepos()
+		changes.repos = diffRepos(changes.lastRepos, repoMap)
+	}
+
 	clearLocal(func(r string) bool {
 		_, ok := repoMap[r]
 		return ok
 	})
 
+	e.transferredOut = e.refreshTransferredOut(ctx, org, getSHA)
+
+	doCheck := func(repo *community.Repository, sig string, owners community.EffectiveOwners) {
+		if repo != nil {
+			if reason := allRepos.GetRenameConflict(repo.Name); reason != "" {
+				e.log.Errorf("skip repo:%s, because it can't be renamed, %s", repo.Name, reason)
+				return
+			}
+
+			if v, ok := e.transferredOut[repo.Name]; ok {
+				e.log.Infof("skip repo:%s, because it is being transferred to %s", repo.Name, v)
+				return
+			}
+		}
+
+		checkRepo(repo, sig, owners, e.log)
+	}
+
+	identities := e.identities.refresh(ctx, getSHA)
+	unresolvedOwners := make(map[string][]string)
+	expectSigs := make(map[string]expectSig)
+
 	done := sets.NewString()
-	allSigs := e.sig.refresh(getSHA)
+	allSigs := e.sig.refresh(ctx, getSHA)
 	sigs := allSigs.GetSigs()
+
+	e.sigFreezes = make(map[string]*community.Freeze, len(sigs))
+	for i := range sigs {
+		e.sigFreezes[sigs[i].Name] = &sigs[i].Freeze
+	}
+
+	// The owners are refreshed before checking the repos, so that the changes can be tracked first.
+	allOwners := make([]*community.RepoOwners, len(sigs))
 	for i := range sigs {
 		sig := &sigs[i]
 
 		sigOwner := e.getSigOwner(sig.Name)
-		owners := sigOwner.refresh(getSHA)
+		lastSHA := sigOwner.wf.sha
+		allOwners[i] = sigOwner.refresh(ctx, getSHA)
+
+		if lastSHA != "" && lastSHA != sigOwner.wf.sha {
+			changes.owners[sigOwner.wf.file] = sig.GetRepos(org)
+		}
+	}
 
+	if trackChanges != nil && !changes.isEmpty() {
+		trackChanges(&changes)
+	}
+
+	for i := range sigs {
+		sig := &sigs[i]
+		owners := allOwners[i]
+
+		sigRepos := []string{}
+		frozenRepos := []string{}
+		unresolved := sets.NewString()
 		for _, repoName := range sig.GetRepos(org) {
 			if isStopped() {
 				break
@@ -176,16 +327,51 @@ func (e *expectState) check(
 				continue
 			}
 
-			checkRepo(repoMap[repoName], owners.GetOwners(), e.log)
+			v, failed := e.users.resolveOwners(ctx, owners.GetOwnersOfRepo(repoName), identities)
+			unresolved.Insert(failed...)
+
+			doCheck(repoMap[repoName], sig.Name, v)
 
 			done.Insert(repoName)
+
+			if repo, ok := repoMap[repoName]; ok {
+				if repo.GetFrozenReason(time.Now()) != "" {
+					frozenRepos = append(frozenRepos, repoName)
+				} else {
+					sigRepos = append(sigRepos, repoName)
+				}
+			}
 		}
 
 		if isStopped() {
 			break
 		}
+
+		maintainers, failed := e.users.resolveOwners(
+			ctx, community.EffectiveOwners{Maintainers: owners.GetMaintainers()}, identities,
+		)
+		unresolved.Insert(failed...)
+
+		expectSigs[sig.Name] = expectSig{
+			maintainers: maintainers.Maintainers,
+			repos:       sigRepos,
+			frozenRepos: frozenRepos,
+			frozen:      sig.GetFrozenReason(time.Now()),
+		}
+
+		if unresolved.Len() > 0 {
+			unresolvedOwners[sig.Name] = unresolved.List()
+
+			e.log.Errorf(
+				"the owners of sig:%s can't be resolved, they are:%s",
+				sig.Name, strings.Join(unresolved.List(), ", "),
+			)
+		}
 	}
 
+	e.unresolvedOwners = unresolvedOwners
+	e.sigs = expectSigs
+
 	if len(repoMap) == done.Len() {
 		return
 	}
@@ -200,9 +386,63 @@ func (e *expectState) check(
 				continue
 			}
 
-			checkRepo(repo, nil, e.log)
+			doCheck(repo, "", community.EffectiveOwners{})
+		}
+	}
+}
+
+// getFrozenReason returns why the repo of sig can't be changed now.
+// It returns empty string if it can be changed.
+func (e *expectState) getFrozenReason(repo *community.Repository, sig string) string {
+	now := time.Now()
+
+	if v := repo.GetFrozenReason(now); v != "" {
+		return "the repo is " + v
+	}
+
+	if f, ok := e.sigFreezes[sig]; ok {
+		if v := f.GetFrozenReason(now); v != "" {
+			return fmt.Sprintf("the sig:%s is %s", sig, v)
+		}
+	}
+
+	return ""
+}
+
+// diffRepos returns the repos which are new or changed.
+func diffRepos(last, current map[string]*community.Repository) []string {
+	r := []string{}
+	for k, v := range current {
+		if old, ok := last[k]; !ok || !reflect.DeepEqual(old, v) {
+			r = append(r, k)
+		}
+	}
+
+	return r
+}
+
+func (e *expectState) getRepos() map[string]*community.Repository {
+	if v, ok := e.repos.wf.obj.(*community.Repos); ok {
+		return v.GetRepos()
+	}
+	return nil
+}
+
+func (e *expectState) refreshTransferredOut(ctx context.Context, org string, f getSHAFunc) map[string]string {
+	r := make(map[string]string)
+
+	for i := range e.peers {
+		v := e.peers[i].refresh(ctx, f)
+		peer := v.GetCommunity()
+
+		for name, repo := range v.GetRepos() {
+			if o, n := repo.GetTransferFrom(); o == org {
+				r[n] = peer + "/" + name
+			}
 		}
 	}
+
+	return r
 }
 
 func (e *expectState) getSigOwner(sigName string) *expectSigOwners {
@@ -228,8 +468,30 @@ func (e *expectState) newWatchingFile(p string) watchingFile {
 	}
 }
 
-func (e *expectState) listAllFilesOfRepo() (map[string]string, error) {
-	trees, err := e.cli.GetDirectoryTree(e.w.Org, e.w.Repo, e.w.Branch, 1)
+// refreshCommit updates the commit to the head of branch. It is cleared
+// if failed, and the files will be loaded at the head of branch.
+func (e *expectState) refreshCommit(ctx context.Context) {
+	sha, err := e.cli.GetRef(ctx, e.w.Org, e.w.Repo, e.w.Branch)
+	if err != nil {
+		e.log.Errorf("get the head of branch:%s, err:%s", e.w.Branch, err.Error())
+
+		e.commit = ""
+		return
+	}
+
+	e.commit = sha
+}
+
+func (e *expectState) getRef() string {
+	if e.commit != "" {
+		return e.commit
+	}
+
+	return e.w.Branch
+}
+
+func (e *expectState) listAllFilesOfRepo(ctx context.Context) (map[string]string, error) {
+	trees, err := e.cli.GetDirectoryTree(ctx, e.w.Org, e.w.Repo, e.getRef(), 1)
 	if err != nil || len(trees.Tree) == 0 {
 		return nil, err
 	}
@@ -243,8 +505,8 @@ func (e *expectState) listAllFilesOfRepo() (map[string]string, error) {
 	return r, nil
 }
 
-func (e *expectState) loadFile(f string) (string, string, error) {
-	c, err := e.cli.GetPathContent(e.w.Org, e.w.Repo, f, e.w.Branch)
+func (e *expectState) loadFile(ctx context.Context, f string) (string, string, error) {
+	c, err := e.cli.GetPathContent(ctx, e.w.Org, e.w.Repo, f, e.getRef())
 	if err != nil {
 		return "", "", err
 	}
@@ -6,7 +6,6 @@ require (
 	gitee.com/openeuler/go-gitee v0.0.0-20211126062945-e0d9fc01dbaa
 	github.com/huaweicloud/golangsdk v0.0.0-20210831081626-d823fe11ceba
 	github.com/opensourceways/community-robot-lib v0.0.0-20211127100111-9925e60f0b14
-	github.com/panjf2000/ants/v2 v2.4.6
 	github.com/sirupsen/logrus v1.8.1
 	k8s.io/apimachinery v0.22.4
 	sigs.k8s.io/yaml v1.3.0
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/sirupsen/logrus"
@@ -10,6 +11,7 @@ import (
 )
 
 func (bot *robot) handleBranch(
+	ctx context.Context,
 	expectRepo expectRepoInfo,
 	localBranches []community.RepoBranch,
 	log *logrus.Entry,
@@ -18,47 +20,60 @@ func (bot *robot) handleBranch(
 	repo := expectRepo.getNewRepoName()
 
 	if len(localBranches) == 0 {
-		v, err := bot.listAllBranchOfRepo(org, repo)
+		v, err := bot.listAllBranchOfRepo(ctx, org, repo)
 		if err != nil {
 			log.Errorf("handle branch and list all branch of repo:%s, err:%s", repo, err.Error())
+			expectRepo.result.fail(stepBranch, err)
+
 			return nil
 		}
 		localBranches = v
 	}
 
-	bsExpect := genBranchSets(expectRepo.expectRepoState.Branches)
-	bsLocal := genBranchSets(localBranches)
-	newState := []community.RepoBranch{}
+	d := diffBranches(expectRepo.getExpectBranches(), localBranches)
+	newState := append([]community.RepoBranch{}, d.kept...)
 
 	// update
-	if v := bsExpect.intersectionByName(&bsLocal); len(v) > 0 {
-		for name := range v {
-			eb := bsExpect.get(name)
-			lb := bsLocal.get(name)
-			if eb.Type != lb.Type {
-				l := log.WithField("update branch", fmt.Sprintf("%s/%s", repo, name))
-				l.Info("start")
-
-				err := bot.updateBranch(
-					org, repo, name, eb.Type == community.BranchProtected,
-				)
-				if err == nil {
-					newState = append(newState, *eb)
-					continue
-				} else {
-					l.WithField("type", eb.Type).Error(err)
-				}
+	for i := range d.updated {
+		eb := &d.updated[i].expect
+		lb := &d.updated[i].actual
+		name := eb.Name
+
+		if !isCancelled(ctx) {
+			l := log.WithField("update branch", fmt.Sprintf("%s/%s", repo, name))
+			l.Info("start")
+
+			err := bot.updateBranch(
+				ctx,
+				org, repo, name, eb.Type == community.BranchProtected,
+			)
+			if err == nil {
+				newState = append(newState, *eb)
+
+				bot.events.add(eventBranchUpdated, &expectRepo, name)
+				continue
+			} else {
+				l.WithField("type", eb.Type).Error(err)
+				expectRepo.result.failf(stepBranch, "update branch:%s, err:%s", name, err.Error())
 			}
-			newState = append(newState, *lb)
 		}
+		newState = append(newState, *lb)
 	}
 
 	// add new
-	if v := bsExpect.differenceByName(&bsLocal); len(v) > 0 {
-		for _, item := range v {
-			if b, ok := bot.createBranch(org, repo, item, log); ok {
-				newState = append(newState, b)
-			}
+	for _, item := range d.missing {
+		if isCancelled(ctx) {
+			break
+		}
+
+		if b, ok := bot.createBranch(ctx, org, repo, item, log); ok {
+			newState = append(newState, b)
+
+			bot.events.add(eventBranchCreated, &expectRepo, b.Name)
+
+			bot.createOBSBranchProject(ctx, &expectRepo, b.Name, log)
+		} else {
+			expectRepo.result.failf(stepBranch, "create branch:%s failed", item.Name)
 		}
 	}
 
@@ -66,29 +81,26 @@ func (bot *robot) handleBranch(
 }
 
 func (bot *robot) createBranch(
+	ctx context.Context,
 	org, repo string,
 	branch community.RepoBranch,
 	log *logrus.Entry,
 ) (community.RepoBranch, bool) {
-	ref := branch.CreateFrom
-	if ref == "" {
-		// ref must be passed according to the gitee api and the default value is "master"
-		ref = community.BranchMaster
-	}
+	ref := getCreateFrom(&branch)
 
 	log = log.WithField("create branch", fmt.Sprintf("%s/%s", repo, branch.Name))
 	log.Info("start")
 
-	err := bot.cli.CreateBranch(org, repo, branch.Name, ref)
+	err := bot.cli.CreateBranch(ctx, org, repo, branch.Name, ref)
 	if err != nil {
-		if _, err1 := bot.cli.GetRef(org, repo, branch.Name); err1 != nil {
+		if _, err1 := bot.cli.GetRef(ctx, org, repo, branch.Name); err1 != nil {
 			log.WithField("CreateFrom", ref).Error(err)
 			return community.RepoBranch{}, false
 		}
 	}
 
 	if branch.Type == community.BranchProtected {
-		if err := bot.cli.SetProtectionBranch(org, repo, branch.Name); err != nil {
+		if err := bot.cli.SetProtectionBranch(ctx, org, repo, branch.Name); err != nil {
 			log.Errorf("set the branch to be protected, err:%s", err.Error())
 
 			return community.RepoBranch{
@@ -101,15 +113,25 @@ func (bot *robot) createBranch(
 	return branch, true
 }
 
-func (bot *robot) updateBranch(org, repo, branch string, protected bool) error {
+// getCreateFrom returns the branch which the branch will be created from.
+func getCreateFrom(branch *community.RepoBranch) string {
+	if branch.CreateFrom == "" {
+		// ref must be passed according to the gitee api and the default value is "master"
+		return community.BranchMaster
+	}
+
+	return branch.CreateFrom
+}
+
+func (bot *robot) updateBranch(ctx context.Context, org, repo, branch string, protected bool) error {
 	if protected {
-		return bot.cli.SetProtectionBranch(org, repo, branch)
+		return bot.cli.SetProtectionBranch(ctx, org, repo, branch)
 	}
-	return bot.cli.CancelProtectionBranch(org, repo, branch)
+	return bot.cli.CancelProtectionBranch(ctx, org, repo, branch)
 }
 
-func (bot *robot) listAllBranchOfRepo(org, repo string) ([]community.RepoBranch, error) {
-	items, err := bot.cli.GetRepoAllBranch(org, repo)
+func (bot *robot) listAllBranchOfRepo(ctx context.Context, org, repo string) ([]community.RepoBranch, error) {
+	items, err := bot.cli.GetRepoAllBranch(ctx, org, repo)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+// reportFrozenRepo reports how the frozen repo is different from the expectation
+// without changing it. The task of repo which was queued before is dropped.
+func (bot *robot) reportFrozenRepo(localRepo *models.Repo, expectRepo *expectRepoInfo, log *logrus.Entry) {
+	repo := expectRepo.getNewRepoName()
+
+	bot.queue.drop(repo)
+	bot.feedback.skip(repo)
+
+	s := localRepo.State()
+	if v := diffRepoState(&s, expectRepo); len(v) > 0 {
+		log.Warnf(
+			"skip repo:%s, because %s. it is not as expected, %s",
+			repo, expectRepo.frozen, strings.Join(v, "; "),
+		)
+	} else {
+		log.Infof("skip repo:%s, because %s", repo, expectRepo.frozen)
+	}
+}
+
+// diffRepoState returns the differences between the state and the expectation of repo.
+// The branches are compared only if they have been loaded.
+func diffRepoState(s *models.RepoState, expectRepo *expectRepoInfo) []string {
+	if !s.Available {
+		return []string{"the repo does not exist"}
+	}
+
+	d := diffRepo(expectRepo, s)
+
+	r := []string{}
+
+	if d.propertyChanged {
+		r = append(r, fmt.Sprintf(
+			"the property is private=%t, commentable=%t", s.Property.Private, s.Property.CanComment,
+		))
+	}
+
+	if v := d.members.missing; len(v) > 0 {
+		r = append(r, "missing members: "+strings.Join(v, ", "))
+	}
+
+	if v := d.members.unexpected; len(v) > 0 {
+		r = append(r, "unexpected members: "+strings.Join(v, ", "))
+	}
+
+	if len(s.Branches) > 0 {
+		if v := d.branches.missing; len(v) > 0 {
+			r = append(r, "missing branches: "+strings.Join(branchNames(v), ", "))
+		}
+
+		if v := d.branches.updated; len(v) > 0 {
+			names := make([]string, len(v))
+			for i := range v {
+				names[i] = v[i].expect.Name
+			}
+
+			r = append(r, "branches with unexpected protection: "+strings.Join(names, ", "))
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
+
+type hookHandler func(context.Context, *expectRepoInfo, *logrus.Entry) error
+
+type registeredHook struct {
+	name     string
+	order    int
+	onRename bool
+	handle   hookHandler
+}
+
+// repoHooks are the enabled hooks sorted by the order.
+type repoHooks []registeredHook
+
+func (bot *robot) newRepoHooks() repoHooks {
+	r := repoHooks{}
+
+	if bot.cfg.EnableCreatingOBSMetaProject {
+		r = append(r, registeredHook{
+			name:     hookTypeOBSMetaProject,
+			onRename: true,
+			handle:   bot.createOBSMetaProject,
+		})
+	}
+
+	for i := range bot.cfg.Hooks {
+		item := &bot.cfg.Hooks[i]
+		if !item.Enable {
+			continue
+		}
+
+		h := registeredHook{
+			name:     item.Name,
+			order:    item.Order,
+			onRename: item.OnRename,
+		}
+
+		switch item.Type {
+		case hookTypeOBSMetaProject:
+			if bot.cfg.EnableCreatingOBSMetaProject {
+				// it was registered above.
+				continue
+			}
+			h.handle = bot.createOBSMetaProject
+
+		case hookTypeWriteFiles, hookTypeSeedFiles:
+			h.handle = bot.genWriteFilesHook(item)
+
+		case hookTypeInitLabels:
+			h.handle = bot.genInitLabelsHook(item)
+
+		case hookTypeAddWebhooks:
+			h.handle = bot.genAddWebhooksHook(item)
+		}
+
+		r = append(r, h)
+	}
+
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].order < r[j].order
+	})
+
+	return r
+}
+
+// runOnCreate runs the hooks and returns the ones which failed or were not run.
+func (hs repoHooks) runOnCreate(ctx context.Context, expectRepo expectRepoInfo, log *logrus.Entry) []string {
+	return hs.run(ctx, &expectRepo, false, log)
+}
+
+// runOnRename runs the hooks for renamed repo and returns the ones which failed or were not run.
+func (hs repoHooks) runOnRename(ctx context.Context, expectRepo expectRepoInfo, log *logrus.Entry) []string {
+	return hs.run(ctx, &expectRepo, true, log)
+}
+
+func (hs repoHooks) run(ctx context.Context, expectRepo *expectRepoInfo, renamed bool, log *logrus.Entry) []string {
+	names := sets.NewString()
+	for i := range hs {
+		if h := &hs[i]; !renamed || h.onRename {
+			names.Insert(h.name)
+		}
+	}
+
+	return hs.runHooks(ctx, expectRepo, names, log)
+}
+
+// runHooks runs the hooks of names in order. It returns the ones which failed
+// or were not run because the ctx is done, so that they can be run again.
+func (hs repoHooks) runHooks(
+	ctx context.Context, expectRepo *expectRepoInfo, names sets.String, log *logrus.Entry,
+) []string {
+	failed := []string{}
+
+	for i := range hs {
+		h := &hs[i]
+		if !names.Has(h.name) {
+			continue
+		}
+
+		if isCancelled(ctx) {
+			failed = append(failed, h.name)
+			continue
+		}
+
+		l := log.WithField("hook", h.name)
+		if err := h.handle(ctx, expectRepo, l); err != nil {
+			l.Errorf("run hook, err:%s", err.Error())
+			expectRepo.result.failf(stepHook, "hook:%s, err:%s", h.name, err.Error())
+
+			failed = append(failed, h.name)
+		}
+	}
+
+	if len(failed) > 0 {
+		log.Errorf(
+			"%d hooks of repo:%s failed or were not run, they will be run next time, they are:%s",
+			len(failed), expectRepo.getNewRepoName(), strings.Join(failed, ", "),
+		)
+	}
+
+	return failed
+}
+
+func (bot *robot) genWriteFilesHook(cfg *repoHook) hookHandler {
+	return func(ctx context.Context, expectRepo *expectRepoInfo, log *logrus.Entry) error {
+		data := newRepoTemplateData(expectRepo, community.BranchMaster)
+
+		failed := []string{}
+		for i := range cfg.Files {
+			if isCancelled(ctx) {
+				break
+			}
+
+			f := &cfg.Files[i]
+
+			target := f.Target
+			if cfg.Type == hookTypeSeedFiles {
+				target = repoBranch{
+					Org:    expectRepo.org,
+					Repo:   expectRepo.getNewRepoName(),
+					Branch: community.BranchMaster,
+				}
+			}
+
+			if err := bot.writeHookFile(ctx, target, f, &data, cfg.Name); err != nil {
+				log.Errorf("write file:%s, err:%s", f.Path, err.Error())
+
+				failed = append(failed, f.Path)
+			}
+		}
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to write files:%s", strings.Join(failed, ", "))
+		}
+		return nil
+	}
+}
+
+func (bot *robot) writeHookFile(
+	ctx context.Context, b repoBranch, f *hookFile, data *repoTemplateData, hookName string,
+) error {
+	p, err := execTemplate(f.pathTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	// file exists
+	if _, err := bot.cli.GetPathContent(ctx, b.Org, b.Repo, p, b.Branch); err == nil {
+		return nil
+	}
+
+	content, err := execTemplate(f.contentTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	msg := f.CommitMessage
+	if msg == "" {
+		msg = fmt.Sprintf("add file by the hook:%s for repo:%s/%s", hookName, data.Org, data.Name)
+	}
+
+	_, err = bot.cli.CreateFile(ctx, b.Org, b.Repo, b.Branch, p, content, msg)
+
+	return err
+}
+
+func (bot *robot) genInitLabelsHook(cfg *repoHook) hookHandler {
+	return func(ctx context.Context, expectRepo *expectRepoInfo, log *logrus.Entry) error {
+		org := expectRepo.org
+		repo := expectRepo.getNewRepoName()
+
+		v, err := bot.cli.ListRepoLabels(ctx, org, repo)
+		if err != nil {
+			return err
+		}
+
+		exists := sets.NewString()
+		for i := range v {
+			exists.Insert(v[i].Name)
+		}
+
+		failed := []string{}
+		for _, item := range cfg.Labels {
+			if isCancelled(ctx) {
+				break
+			}
+
+			if exists.Has(item.Name) {
+				continue
+			}
+
+			if err := bot.cli.CreateRepoLabel(ctx, org, repo, item); err != nil {
+				log.Errorf("create label:%s, err:%s", item.Name, err.Error())
+
+				failed = append(failed, item.Name)
+			}
+		}
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to create labels:%s", strings.Join(failed, ", "))
+		}
+		return nil
+	}
+}
+
+func (bot *robot) genAddWebhooksHook(cfg *repoHook) hookHandler {
+	return func(ctx context.Context, expectRepo *expectRepoInfo, log *logrus.Entry) error {
+		org := expectRepo.org
+		repo := expectRepo.getNewRepoName()
+
+		v, err := bot.cli.ListRepoWebhooks(ctx, org, repo)
+		if err != nil {
+			return err
+		}
+
+		exists := sets.NewString()
+		for i := range v {
+			exists.Insert(v[i].URL)
+		}
+
+		failed := []string{}
+		for i := range cfg.Webhooks {
+			if isCancelled(ctx) {
+				break
+			}
+
+			item := &cfg.Webhooks[i]
+			if exists.Has(item.URL) {
+				continue
+			}
+
+			if err := bot.cli.CreateRepoWebhook(ctx, org, repo, item.toRepoWebhook()); err != nil {
+				log.Errorf("add webhook:%s, err:%s", item.URL, err.Error())
+
+				failed = append(failed, item.URL)
+			}
+		}
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to add webhooks:%s", strings.Join(failed, ", "))
+		}
+		return nil
+	}
+}
@@ -1,64 +1,120 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
 )
 
-func (bot *robot) handleMember(expectRepo expectRepoInfo, localMembers []string, repoOwner *string, log *logrus.Entry) []string {
+func (bot *robot) handleMember(
+	ctx context.Context,
+	expectRepo expectRepoInfo,
+	localMembers []string,
+	repoOwner *string,
+	log *logrus.Entry,
+) []string {
 	org := expectRepo.org
 	repo := expectRepo.getNewRepoName()
 
 	if len(localMembers) == 0 {
-		v, err := bot.cli.GetRepo(org, repo)
+		v, err := bot.cli.GetRepo(ctx, org, repo)
 		if err != nil {
 			log.Errorf("handle repo members and get repo:%s, err:%s", repo, err.Error())
+			expectRepo.result.fail(stepMember, err)
+
 			return nil
 		}
 		localMembers = toLowerOfMembers(v.Members)
 		*repoOwner = v.Owner.Login
 	}
 
-	expect := sets.NewString(expectRepo.expectOwners...)
-	lm := sets.NewString(localMembers...)
-	r := expect.Intersection(lm).UnsortedList()
+	// Gitee does not allow to remove the repo owner, so it is not unexpected.
+	d := diffMembers(expectRepo.expectOwners, localMembers, *repoOwner)
+	r := append([]string{}, d.kept...)
 
 	// add new
-	if v := expect.Difference(lm); v.Len() > 0 {
-		for k := range v {
-			l := log.WithField("add member", fmt.Sprintf("%s:%s", repo, k))
-			l.Info("start")
-
-			// how about adding a member but he/she exits? see the comment of 'addRepoMember'
-			if err := bot.addRepoMember(org, repo, k); err != nil {
-				l.Error(err)
-			} else {
-				r = append(r, k)
-			}
+	for _, k := range d.missing {
+		if isCancelled(ctx) {
+			break
+		}
+
+		l := log.WithField("add member", fmt.Sprintf("%s:%s", repo, k))
+		l.Info("start")
+
+		// how about adding a member but he/she exits? see the comment of 'addRepoMember'
+		if err := bot.addRepoMember(ctx, org, repo, k, expectRepo.getMemberPermission()); err != nil {
+			l.Error(err)
+			expectRepo.result.failf(stepMember, "add member:%s, err:%s", k, err.Error())
+		} else {
+			r = append(r, k)
+
+			bot.events.add(eventMemberAdded, &expectRepo, k)
 		}
 	}
 
 	// remove
-	if v := lm.Difference(expect); v.Len() > 0 {
-		o := *repoOwner
+	toRemove := sets.NewString(bot.membersToRemove(&expectRepo, d.unexpected)...)
+	for _, k := range d.unexpected {
+		if !toRemove.Has(k) {
+			r = append(r, k)
+			continue
+		}
 
-		for k := range v {
-			if k == o {
-				// Gitee does not allow to remove the repo owner.
-				continue
-			}
+		if bot.cfg.UnexpectedMembers.ReportOnly {
+			log.Warnf("unexpected member:%s of repo:%s", k, repo)
 
-			l := log.WithField("remove member", fmt.Sprintf("%s:%s", repo, k))
-			l.Info("start")
+			r = append(r, k)
+			continue
+		}
+
+		if isCancelled(ctx) {
+			r = append(r, k)
+			continue
+		}
 
-			if err := bot.cli.RemoveRepoMember(org, repo, k); err != nil {
-				l.Error(err)
+		l := log.WithField("remove member", fmt.Sprintf("%s:%s", repo, k))
+		l.Info("start")
+
+		if err := bot.cli.RemoveRepoMember(ctx, org, repo, k); err != nil {
+			l.Error(err)
+			expectRepo.result.failf(stepMember, "remove member:%s, err:%s", k, err.Error())
+
+			r = append(r, k)
+		} else {
+			bot.events.add(eventMemberRemoved, &expectRepo, k)
+		}
+	}
+
+	return r
+}
+
+// hasMembersToRemove returns true if some members of repo will be removed.
+func (bot *robot) hasMembersToRemove(expectRepo *expectRepoInfo, state *models.RepoState) bool {
+	if bot.cfg.UnexpectedMembers.ReportOnly {
+		return false
+	}
+
+	d := diffMembers(expectRepo.expectOwners, state.Members, state.Owner)
+
+	return len(bot.membersToRemove(expectRepo, d.unexpected)) > 0
+}
+
+// membersToRemove returns the unexpected members which are not in the allowlist.
+// They are only reported instead of being removed if it is report only.
+func (bot *robot) membersToRemove(expectRepo *expectRepoInfo, unexpected []string) []string {
+	cfg := &bot.cfg.UnexpectedMembers
+	org := expectRepo.org
+	repo := expectRepo.getNewRepoName()
 
-				r = append(r, k)
-			}
+	r := []string{}
+	for _, k := range unexpected {
+		if !cfg.Allowlist.has(org, expectRepo.sig, repo, k) {
+			r = append(r, k)
 		}
 	}
 
@@ -66,8 +122,9 @@ func (bot *robot) handleMember(expectRepo expectRepoInfo, localMembers []string,
 }
 
 // Gitee api will be successful even if adding a member repeatedly.
-func (bot *robot) addRepoMember(org, repo, login string) error {
-	return bot.cli.AddRepoMember(org, repo, login, "push")
+// The permission of member will be updated if it is different.
+func (bot *robot) addRepoMember(ctx context.Context, org, repo, login, permission string) error {
+	return bot.cli.AddRepoMember(ctx, org, repo, login, permission)
 }
 
 func toLowerOfMembers(m []string) []string {
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxOBSMetaPRBodySize is the max size of body of the pull request of obs meta.
+// The changes are omitted in the body if it exceeds, and they can be seen in the commits.
+const maxOBSMetaPRBodySize = 20000
+
+const obsMetaPROmitted = "- ... the other changes are omitted, please see the commits"
+
+type obsFileChange struct {
+	path    string
+	content string
+	msg     string
+	remove  bool
+
+	// sha is the sha of file which will be updated or removed.
+	// It is empty when creating the file.
+	sha string
+}
+
+// obsFileChanges are the changes of project files which will be submitted by a pull request.
+type obsFileChanges struct {
+	lock  sync.Mutex
+	items map[string]obsFileChange
+}
+
+func (c *obsFileChanges) add(change *obsFileChange) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.items == nil {
+		c.items = make(map[string]obsFileChange)
+	}
+	c.items[change.path] = *change
+}
+
+// restore adds back the changes which were failed to submit unless there are newer ones.
+func (c *obsFileChanges) restore(changes []obsFileChange) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.items == nil {
+		c.items = make(map[string]obsFileChange)
+	}
+
+	for i := range changes {
+		item := &changes[i]
+		if _, ok := c.items[item.path]; !ok {
+			c.items[item.path] = *item
+		}
+	}
+}
+
+func (c *obsFileChanges) take() []obsFileChange {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	r := make([]obsFileChange, 0, len(c.items))
+	for _, v := range c.items {
+		r = append(r, v)
+	}
+	c.items = nil
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].path < r[j].path
+	})
+
+	return r
+}
+
+// submitOBSMetaChanges commits the staged changes to the branch of bot and
+// submits them by a pull request. It will reuse the pull request if it is still open.
+// The project files are not cached until the pull request is merged, so the changes
+// will be staged again if the pull request is closed or they are failed to submit.
+func (bot *robot) submitOBSMetaChanges(ctx context.Context, log *logrus.Entry) {
+	project := &bot.cfg.OBSMetaProject
+	if !bot.cfg.needOBSMetaProject() || !project.CommitByPR || isCancelled(ctx) {
+		return
+	}
+
+	changes := bot.obsChanges.take()
+	if len(changes) == 0 {
+		return
+	}
+
+	base := &project.Branch
+	head := repoBranch{Org: base.Org, Repo: base.Repo}
+
+	pr, err := bot.findOBSMetaPR(ctx)
+	if err != nil {
+		log.Errorf("find the pull request of obs meta, err:%s", err.Error())
+
+		bot.obsChanges.restore(changes)
+		return
+	}
+
+	if pr != nil {
+		head.Branch = pr.Head.Ref
+	} else {
+		head.Branch = fmt.Sprintf("%s-%s", project.PRBranchPrefix, time.Now().Format("20060102150405"))
+
+		if err := bot.cli.CreateBranch(ctx, base.Org, base.Repo, head.Branch, base.Branch); err != nil {
+			log.Errorf("create branch:%s, err:%s", head.Branch, err.Error())
+
+			bot.obsChanges.restore(changes)
+			return
+		}
+	}
+
+	done := []string{}
+	failed := []obsFileChange{}
+	for i := range changes {
+		if isCancelled(ctx) {
+			failed = append(failed, changes[i:]...)
+			break
+		}
+
+		item := &changes[i]
+		changed, err := bot.applyOBSFileChange(ctx, &head, item)
+		if err != nil {
+			log.Error(err)
+
+			failed = append(failed, *item)
+		} else if changed {
+			done = append(done, "- "+item.msg)
+		}
+	}
+
+	bot.obsChanges.restore(failed)
+
+	if len(done) == 0 {
+		return
+	}
+
+	if pr != nil {
+		err = bot.cli.UpdatePullRequest(ctx, base.Org, base.Repo, pr.Number, pullRequestParam{
+			Body: appendOBSMetaPRBody(pr.Body, done),
+		})
+	} else {
+		_, err = bot.cli.CreatePullRequest(ctx, base.Org, base.Repo, pullRequestParam{
+			Title: "update obs meta projects",
+			Head:  head.Branch,
+			Base:  base.Branch,
+			Body:  appendOBSMetaPRBody("", done),
+		})
+	}
+
+	if err != nil {
+		log.Errorf("submit pull request of obs meta, err:%s", err.Error())
+	}
+}
+
+// findOBSMetaPR finds the open pull request which was created by the bot before.
+func (bot *robot) findOBSMetaPR(ctx context.Context) (*pullRequest, error) {
+	project := &bot.cfg.OBSMetaProject
+	b := &project.Branch
+
+	prs, err := bot.cli.ListPullRequests(ctx, b.Org, b.Repo, "open", "", b.Branch)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range prs {
+		if strings.HasPrefix(prs[i].Head.Ref, project.PRBranchPrefix) {
+			return &prs[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// appendOBSMetaPRBody appends the lines to the body until it exceeds the max size.
+func appendOBSMetaPRBody(body string, lines []string) string {
+	if strings.HasSuffix(body, obsMetaPROmitted) {
+		return body
+	}
+
+	r := []string{}
+	if body != "" {
+		r = append(r, body)
+	}
+
+	n := len(body)
+	for _, item := range lines {
+		if n+len(item)+len(obsMetaPROmitted)+2 > maxOBSMetaPRBodySize {
+			r = append(r, obsMetaPROmitted)
+			break
+		}
+
+		r = append(r, item)
+		n += len(item) + 1
+	}
+
+	return strings.Join(r, "\n")
+}
+
+// applyOBSFileChange applies the change to the branch of bot on which the file may be
+// different from the one on the base branch because of the former changes.
+// It returns false if the change has been applied before.
+func (bot *robot) applyOBSFileChange(ctx context.Context, b *repoBranch, change *obsFileChange) (bool, error) {
+	v := *change
+	v.sha = ""
+
+	c, err := bot.cli.GetPathContent(ctx, b.Org, b.Repo, change.path, b.Branch)
+	if err != nil && !isNotFound(err) {
+		return false, fmt.Errorf("get file: %s, err:%s", change.path, err.Error())
+	}
+	exists := err == nil
+
+	if change.remove {
+		if !exists {
+			return false, nil
+		}
+	} else if exists && isSameFileContent(c.Content, change.content) {
+		return false, nil
+	}
+
+	if exists {
+		v.sha = c.Sha
+	}
+
+	return true, bot.writeOBSFile(ctx, b, &v)
+}
@@ -1,39 +1,350 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
 )
 
-func (bot *robot) createOBSMetaProject(repo string, log *logrus.Entry) {
-	if !bot.cfg.EnableCreatingOBSMetaProject {
-		return
+// obsProjectCache records the project files which are synced already,
+// so that it does not need to check them again in the next cycle.
+type obsProjectCache struct {
+	lock  sync.Mutex
+	items map[string]string
+}
+
+func (c *obsProjectCache) has(path, content string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	v, ok := c.items[path]
+	return ok && v == content
+}
+
+func (c *obsProjectCache) set(path, content string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.items == nil {
+		c.items = make(map[string]string)
 	}
+	c.items[path] = content
+}
 
-	project := &bot.cfg.OBSMetaProject
-	path := project.genProjectFilePath(repo)
-	b := &project.Branch
+func (c *obsProjectCache) remove(path string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.items, path)
+}
+
+type obsProjectFile struct {
+	path    string
+	content string
+}
+
+// createOBSMetaProject is the hook which is invoked after a repo is created or renamed.
+func (bot *robot) createOBSMetaProject(ctx context.Context, expectRepo *expectRepoInfo, log *logrus.Entry) error {
+	if isCancelled(ctx) {
+		return nil
+	}
+
+	repo := expectRepo.getNewRepoName()
+	if err := bot.writeOBSMetaProject(ctx, expectRepo, bot.cfg.OBSMetaProject.SyncProject); err != nil {
+		return err
+	}
+
+	if old := expectRepo.expectRepoState.RenameFrom; old != "" && old != repo {
+		return bot.removeOBSMetaProject(ctx, expectRepo, old, fmt.Sprintf("it is renamed to %s", repo))
+	}
 
-	// file exists
-	if _, err := bot.cli.GetPathContent(b.Org, b.Repo, path, b.Branch); err == nil {
+	return nil
+}
+
+// createOBSBranchProject creates the projects for the new branch of repo.
+func (bot *robot) createOBSBranchProject(
+	ctx context.Context,
+	expectRepo *expectRepoInfo,
+	branch string,
+	log *logrus.Entry,
+) {
+	if !bot.cfg.needOBSMetaProject() || isCancelled(ctx) {
 		return
 	}
 
-	content, err := project.genProjectFileContent(repo)
+	repo := expectRepo.getNewRepoName()
+
+	files, err := bot.genOBSBranchProjectFiles(expectRepo, []string{branch})
+	if err == nil {
+		err = bot.writeOBSProjectFiles(ctx, repo, files, bot.cfg.OBSMetaProject.SyncProject)
+	}
+
 	if err != nil {
-		log.Errorf("generate file of project:%s, err:%s", repo, err.Error())
+		log.Errorf("create obs project for branch:%s, err:%s", branch, err.Error())
+		expectRepo.result.fail(stepOBSProject, err)
+	}
+}
+
+// syncOBSMetaProject keeps the project files of repo same as the ones generated by the template.
+func (bot *robot) syncOBSMetaProject(ctx context.Context, expectRepo *expectRepoInfo, log *logrus.Entry) {
+	if !bot.cfg.needOBSMetaProject() || !bot.cfg.OBSMetaProject.SyncProject || isCancelled(ctx) {
 		return
 	}
 
-	w := &bot.cfg.WatchingFiles
-	msg := fmt.Sprintf(
-		"add project according to the file: %s/%s/%s:%s",
-		w.Org, w.Repo, w.Branch, w.RepoFilePath,
-	)
+	repo := expectRepo.getNewRepoName()
+	if err := bot.writeOBSMetaProject(ctx, expectRepo, true); err != nil {
+		log.Errorf("sync obs meta project of repo:%s, err:%s", repo, err.Error())
+		expectRepo.result.fail(stepOBSProject, err)
+	}
+}
+
+// removeOBSMetaProjects removes the project files of the repos which are removed from the repo file.
+// The value of repos is the last known expectation of repo.
+func (bot *robot) removeOBSMetaProjects(ctx context.Context, repos map[string]*expectRepoInfo, log *logrus.Entry) {
+	if !bot.cfg.needOBSMetaProject() || !bot.cfg.OBSMetaProject.RemoveProjectOfRemovedRepo {
+		return
+	}
 
-	_, err = bot.cli.CreateFile(b.Org, b.Repo, b.Branch, path, content, msg)
+	for repo, expectRepo := range repos {
+		if isCancelled(ctx) {
+			break
+		}
+
+		if err := bot.removeOBSMetaProject(ctx, expectRepo, repo, "it is removed"); err != nil {
+			log.Errorf("remove obs meta project of repo:%s, err:%s", repo, err.Error())
+		}
+	}
+}
+
+// writeOBSMetaProject writes the project file of repo and the ones of its branches.
+func (bot *robot) writeOBSMetaProject(ctx context.Context, expectRepo *expectRepoInfo, overwrite bool) error {
+	repo := expectRepo.getNewRepoName()
+	project := &bot.cfg.OBSMetaProject
+
+	data := newRepoTemplateData(expectRepo, community.BranchMaster)
+	content, err := project.genProjectFileContent(&data)
 	if err != nil {
-		log.Errorf("ceate file: %s, err:%s", path, err.Error())
+		return fmt.Errorf("generate file of project:%s, err:%s", repo, err.Error())
+	}
+
+	files, err := bot.genOBSBranchProjectFiles(
+		expectRepo, genBranchNames(expectRepo.expectRepoState.Branches),
+	)
+	if err != nil {
+		return err
+	}
+
+	files = append(files, obsProjectFile{
+		path:    project.genProjectFilePath(repo),
+		content: content,
+	})
+
+	return bot.writeOBSProjectFiles(ctx, repo, files, overwrite)
+}
+
+func (bot *robot) genOBSBranchProjectFiles(
+	expectRepo *expectRepoInfo,
+	branches []string,
+) ([]obsProjectFile, error) {
+	items := bot.cfg.OBSMetaProject.BranchProjects
+	r := []obsProjectFile{}
+
+	for _, branch := range branches {
+		data := newRepoTemplateData(expectRepo, branch)
+
+		for i := range items {
+			item := &items[i]
+			if !item.isMatched(branch) {
+				continue
+			}
+
+			p, err := item.genProjectFilePath(&data)
+			if err != nil {
+				return nil, fmt.Errorf("generate path of branch project:%s, err:%s", branch, err.Error())
+			}
+
+			c, err := item.genProjectFileContent(&data)
+			if err != nil {
+				return nil, fmt.Errorf("generate file of branch project:%s, err:%s", branch, err.Error())
+			}
+
+			r = append(r, obsProjectFile{path: p, content: c})
+		}
 	}
+
+	return r, nil
+}
+
+func (bot *robot) writeOBSProjectFiles(ctx context.Context, repo string, files []obsProjectFile, overwrite bool) error {
+	failed := []string{}
+
+	for i := range files {
+		if err := bot.writeOBSProjectFile(ctx, repo, &files[i], overwrite); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// writeOBSProjectFile creates the project file if it does not exist, or updates it
+// if it is different from the one generated by the template and overwrite is true.
+func (bot *robot) writeOBSProjectFile(ctx context.Context, repo string, file *obsProjectFile, overwrite bool) error {
+	path := file.path
+	content := file.content
+
+	if bot.obsProjects.has(path, content) {
+		return nil
+	}
+
+	b := &bot.cfg.OBSMetaProject.Branch
+	change := obsFileChange{path: path, content: content}
+
+	if c, err := bot.cli.GetPathContent(ctx, b.Org, b.Repo, path, b.Branch); err != nil {
+		if !isNotFound(err) {
+			return fmt.Errorf("get file: %s, err:%s", path, err.Error())
+		}
+
+		w := &bot.cfg.WatchingFiles
+		change.msg = fmt.Sprintf(
+			"add project according to the file: %s/%s/%s:%s",
+			w.Org, w.Repo, w.Branch, w.RepoFilePath,
+		)
+	} else {
+		if !overwrite || isSameFileContent(c.Content, content) {
+			bot.obsProjects.set(path, content)
+			return nil
+		}
+
+		change.sha = c.Sha
+		change.msg = fmt.Sprintf("update project %s of %s according to the template", path, repo)
+	}
+
+	if err := bot.commitOBSFileChange(ctx, &change); err != nil {
+		return err
+	}
+
+	// The staged change is not cached until it is merged to the base branch.
+	if !bot.cfg.OBSMetaProject.CommitByPR {
+		bot.obsProjects.set(path, content)
+	}
+	return nil
+}
+
+// removeOBSMetaProject removes the project file of repo and the ones of its branches.
+// The paths are generated with the data of expectRepo in the same way as creating them,
+// except that the name of repo is the one whose files are removed, such as the old name.
+func (bot *robot) removeOBSMetaProject(ctx context.Context, expectRepo *expectRepoInfo, repo string, reason string) error {
+	project := &bot.cfg.OBSMetaProject
+	paths := []string{project.genProjectFilePath(repo)}
+
+	for _, branch := range genBranchNames(expectRepo.expectRepoState.Branches) {
+		data := newRepoTemplateData(expectRepo, branch)
+		data.Name = repo
+
+		for i := range project.BranchProjects {
+			item := &project.BranchProjects[i]
+			if !item.isMatched(branch) {
+				continue
+			}
+
+			if p, err := item.genProjectFilePath(&data); err == nil {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	b := &project.Branch
+	failed := []string{}
+
+	for _, path := range paths {
+		c, err := bot.cli.GetPathContent(ctx, b.Org, b.Repo, path, b.Branch)
+		if err != nil {
+			if !isNotFound(err) {
+				failed = append(failed, fmt.Sprintf("get file: %s, err:%s", path, err.Error()))
+			}
+
+			continue
+		}
+
+		err = bot.commitOBSFileChange(ctx, &obsFileChange{
+			path:   path,
+			sha:    c.Sha,
+			msg:    fmt.Sprintf("remove project %s of %s, because %s", path, repo, reason),
+			remove: true,
+		})
+		if err != nil {
+			failed = append(failed, err.Error())
+		} else {
+			bot.obsProjects.remove(path)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// genBranchNames returns the names of branches including master which always exists.
+func genBranchNames(branches []community.RepoBranch) []string {
+	s := sets.NewString(community.BranchMaster)
+	for i := range branches {
+		s.Insert(branches[i].Name)
+	}
+
+	return s.List()
+}
+
+// commitOBSFileChange commits the change to the obs repo directly,
+// or stages it which will be submitted by a pull request later.
+func (bot *robot) commitOBSFileChange(ctx context.Context, change *obsFileChange) error {
+	project := &bot.cfg.OBSMetaProject
+	if project.CommitByPR {
+		bot.obsChanges.add(change)
+
+		return nil
+	}
+
+	return bot.writeOBSFile(ctx, &project.Branch, change)
+}
+
+func (bot *robot) writeOBSFile(ctx context.Context, b *repoBranch, change *obsFileChange) error {
+	path := change.path
+
+	switch {
+	case change.remove:
+		if err := bot.cli.DeleteFile(ctx, b.Org, b.Repo, b.Branch, path, change.sha, change.msg); err != nil {
+			return fmt.Errorf("delete file: %s, err:%s", path, err.Error())
+		}
+
+	case change.sha == "":
+		if _, err := bot.cli.CreateFile(ctx, b.Org, b.Repo, b.Branch, path, change.content, change.msg); err != nil {
+			return fmt.Errorf("ceate file: %s, err:%s", path, err.Error())
+		}
+
+	default:
+		err := bot.cli.UpdateFile(ctx, b.Org, b.Repo, b.Branch, path, change.content, change.sha, change.msg)
+		if err != nil {
+			return fmt.Errorf("update file: %s, err:%s", path, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func isSameFileContent(encoded, content string) bool {
+	v, err := base64.StdEncoding.DecodeString(encoded)
+
+	return err == nil && string(v) == content
 }
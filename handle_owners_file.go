@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
+
+const ownersFileHeader = "# This file is generated by the repo watcher, please don't edit it.\n"
+
+type repoOwnersContent struct {
+	Maintainers []string `json:"maintainers,omitempty"`
+	Committers  []string `json:"committers,omitempty"`
+	Managers    []string `json:"managers,omitempty"`
+	Developers  []string `json:"developers,omitempty"`
+	Reporters   []string `json:"reporters,omitempty"`
+	Viewers     []string `json:"viewers,omitempty"`
+}
+
+// handleOwnersFile keeps the owners file of repo up to date. It returns the git blob
+// sha of file content which has been committed. The file will only be checked again
+// when the sha of expected content is different from the one committed last time.
+// The sha is only kept in memory, so after restarting the file is compared with
+// the blob sha of it on the branch without fetching the content.
+func (bot *robot) handleOwnersFile(
+	ctx context.Context,
+	expectRepo *expectRepoInfo,
+	committed string,
+	log *logrus.Entry,
+) string {
+	cfg := &bot.cfg.OwnersFile
+	if !cfg.Enable || isCancelled(ctx) {
+		return committed
+	}
+
+	content, err := genOwnersFileContent(cfg.Format, expectRepo)
+	if err != nil {
+		log.Errorf("generate owners file, err:%s", err.Error())
+		expectRepo.result.fail(stepOwnersFile, err)
+
+		return committed
+	}
+
+	hash := gitBlobSHA(content)
+	if hash == committed {
+		return committed
+	}
+
+	org := expectRepo.org
+	repo := expectRepo.getNewRepoName()
+
+	sha, err := bot.getFileSHA(ctx, org, repo, cfg.Path, community.BranchMaster)
+	if err != nil {
+		log.Errorf("get the sha of owners file, err:%s", err.Error())
+		expectRepo.result.fail(stepOwnersFile, err)
+
+		return committed
+	}
+
+	if sha == hash {
+		return hash
+	}
+
+	if sha != "" {
+		err = bot.cli.UpdateFile(
+			ctx,
+			org, repo, community.BranchMaster, cfg.Path, content, sha, cfg.CommitMessage,
+		)
+		if err != nil {
+			log.Errorf("update owners file, err:%s", err.Error())
+			expectRepo.result.fail(stepOwnersFile, err)
+
+			return committed
+		}
+
+		return hash
+	}
+
+	_, err = bot.cli.CreateFile(ctx, org, repo, community.BranchMaster, cfg.Path, content, cfg.CommitMessage)
+	if err != nil {
+		log.Errorf("create owners file, err:%s", err.Error())
+		expectRepo.result.fail(stepOwnersFile, err)
+
+		return committed
+	}
+
+	return hash
+}
+
+// getFileSHA returns the blob sha of file on the branch by listing the trees
+// along the path of file. It returns empty string if the file does not exist.
+func (bot *robot) getFileSHA(ctx context.Context, org, repo, file, branch string) (string, error) {
+	names := strings.Split(path.Clean(file), "/")
+
+	sha := branch
+	for i, name := range names {
+		v, err := bot.cli.GetDirectoryTree(ctx, org, repo, sha, 0)
+		if err != nil {
+			if isNotFound(err) {
+				return "", nil
+			}
+
+			return "", err
+		}
+
+		t := "tree"
+		if i == len(names)-1 {
+			t = "blob"
+		}
+
+		sha = ""
+		for j := range v.Tree {
+			if item := &v.Tree[j]; item.Type == t && item.Path == name {
+				sha = item.Sha
+				break
+			}
+		}
+
+		if sha == "" {
+			return "", nil
+		}
+	}
+
+	return sha, nil
+}
+
+// gitBlobSHA returns the sha of content as a git blob.
+func gitBlobSHA(content string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write([]byte(content))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func genOwnersFileContent(format string, expectRepo *expectRepoInfo) (string, error) {
+	repo := expectRepo.expectRepoState
+
+	v := repoOwnersContent{
+		Maintainers: sortedLowerNames(expectRepo.getMaintainers()),
+		Committers:  sortedLowerNames(expectRepo.committers),
+		Managers:    sortedLowerNames(repo.Managers),
+		Developers:  sortedLowerNames(repo.Developers),
+		Reporters:   sortedLowerNames(repo.Reporters),
+		Viewers:     sortedLowerNames(repo.Viewers),
+	}
+
+	if format == ownersFileFormatCodeOwners {
+		owners := sets.NewString(v.Maintainers...).Insert(v.Committers...).Insert(v.Managers...).List()
+		if len(owners) == 0 {
+			return ownersFileHeader, nil
+		}
+
+		for i := range owners {
+			owners[i] = "@" + owners[i]
+		}
+
+		return fmt.Sprintf("%s* %s\n", ownersFileHeader, strings.Join(owners, " ")), nil
+	}
+
+	b, err := yaml.Marshal(&v)
+	if err != nil {
+		return "", err
+	}
+
+	return ownersFileHeader + string(b), nil
+}
+
+func sortedLowerNames(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+
+	return sets.NewString(toLowerOfMembers(names)...).List()
+}
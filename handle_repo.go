@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -12,62 +13,103 @@ import (
 )
 
 func (bot *robot) createRepo(
+	ctx context.Context,
 	expectRepo expectRepoInfo,
 	log *logrus.Entry,
-	hook func(string, *logrus.Entry),
+	hooks repoHooks,
 ) models.RepoState {
 	org := expectRepo.org
 	repo := expectRepo.expectRepoState
 	repoName := expectRepo.getNewRepoName()
 
 	if n := repo.RenameFrom; n != "" && n != repoName {
-		return bot.renameRepo(expectRepo, log, hook)
+		return bot.renameRepo(ctx, expectRepo, log, hooks)
+	}
+
+	if repo.TransferFrom != "" {
+		return bot.transferRepo(ctx, expectRepo, log, hooks)
 	}
 
 	log = log.WithField("create repo", repoName)
 	log.Info("start")
 
-	property, err := bot.newRepo(org, repo)
+	property, err := bot.newRepo(ctx, org, repo)
 	if err != nil {
 		log.Warning("repo exists already")
 
-		if s, b := bot.getRepoState(org, repoName, log); b {
-			s.Branches = bot.handleBranch(expectRepo, s.Branches, log)
-			s.Members = bot.handleMember(expectRepo, s.Members, &s.Owner, log)
+		if s, b := bot.getRepoState(ctx, org, repoName, log); b {
+			s.Branches = bot.handleBranch(ctx, expectRepo, s.Branches, log)
+			s.Members = bot.handleMember(ctx, expectRepo, s.Members, &s.Owner, log)
 			return s
 		}
 
 		log.Errorf("create repo, err:%s", err.Error())
+		expectRepo.result.fail(stepCreate, err)
 
 		return models.RepoState{}
 	}
 
+	bot.events.add(eventRepoCreated, &expectRepo, "")
+
 	defer func() {
-		hook(repoName, log)
+		bot.failedHooks.set(repoName, hooks.runOnCreate(ctx, expectRepo, log), log)
 	}()
 
+	if repo.HasUpstream() {
+		return bot.initRepoWithUpstream(ctx, expectRepo, log)
+	}
+
 	branches, members := bot.initNewlyCreatedRepo(
-		org, repoName, repo.Branches, expectRepo.expectOwners, log,
+		ctx, org, repoName, expectRepo.getExpectBranches(),
+		expectRepo.expectOwners, expectRepo.getMemberPermission(), log,
 	)
 
+	for _, item := range members {
+		bot.events.add(eventMemberAdded, &expectRepo, item)
+	}
+
+	bot.applyRepoTemplate(ctx, &expectRepo, log)
+
 	return models.RepoState{
-		Available: true,
-		Branches:  branches,
-		Members:   members,
-		Property:  property,
+		Available:      true,
+		Branches:       branches,
+		Members:        members,
+		Property:       property,
+		OwnersFileHash: bot.handleOwnersFile(ctx, &expectRepo, "", log),
 	}
 }
 
-func (bot *robot) newRepo(org string, repo *community.Repository) (models.RepoProperty, error) {
-	err := bot.cli.CreateRepo(org, sdk.RepositoryPostParam{
-		Name:        repo.Name,
-		Description: repo.Description,
-		HasIssues:   true,
-		HasWiki:     true,
-		AutoInit:    true, // set `auto_init` as true to initialize `master` branch with README after repo creation
-		CanComment:  repo.Commentable,
-		Private:     repo.IsPrivate(),
-	})
+func (bot *robot) newRepo(ctx context.Context, org string, repo *community.Repository) (models.RepoProperty, error) {
+	var err error
+
+	switch {
+	case repo.ForkFrom != "":
+		o, r := repo.GetForkFrom()
+		err = bot.cli.ForkRepo(ctx, o, r, org, repo.Name)
+
+	case repo.ImportURL != "":
+		err = bot.cli.ImportRepo(ctx, org, importRepoParam{
+			Name:        repo.Name,
+			Path:        repo.Name,
+			Description: repo.Description,
+			ImportURL:   repo.ImportURL,
+			HasIssues:   true,
+			HasWiki:     true,
+			CanComment:  repo.Commentable,
+			Private:     repo.IsPrivate(),
+		})
+
+	default:
+		err = bot.cli.CreateRepo(ctx, org, sdk.RepositoryPostParam{
+			Name:        repo.Name,
+			Description: repo.Description,
+			HasIssues:   true,
+			HasWiki:     true,
+			AutoInit:    true, // set `auto_init` as true to initialize `master` branch with README after repo creation
+			CanComment:  repo.Commentable,
+			Private:     repo.IsPrivate(),
+		})
+	}
 	if err != nil {
 		return models.RepoProperty{}, err
 	}
@@ -78,13 +120,46 @@ func (bot *robot) newRepo(org string, repo *community.Repository) (models.RepoPr
 	}, nil
 }
 
+// initRepoWithUpstream initializes the repo which is forked or imported from an upstream.
+// Unlike the empty repo, it may have branches already, so they are handled as usual.
+func (bot *robot) initRepoWithUpstream(
+	ctx context.Context,
+	expectRepo expectRepoInfo,
+	log *logrus.Entry,
+) models.RepoState {
+	org := expectRepo.org
+	repoName := expectRepo.getNewRepoName()
+
+	if err := bot.initRepoReviewer(ctx, org, repoName); err != nil {
+		log.Errorf("initialize the reviewers, err:%s", err.Error())
+	}
+
+	s, b := bot.getRepoState(ctx, org, repoName, log)
+	if !b {
+		// the branches, members and template will be handled next time.
+		bot.templates.set(repoName, true, log)
+
+		return models.RepoState{Available: true}
+	}
+
+	s.Branches = bot.handleBranch(ctx, expectRepo, s.Branches, log)
+	s.Members = bot.handleMember(ctx, expectRepo, s.Members, &s.Owner, log)
+	s.Property = bot.updateRepo(ctx, expectRepo, s.Property, log)
+	bot.applyRepoTemplate(ctx, &expectRepo, log)
+	s.OwnersFileHash = bot.handleOwnersFile(ctx, &expectRepo, "", log)
+
+	return s
+}
+
 func (bot *robot) initNewlyCreatedRepo(
+	ctx context.Context,
 	org, repoName string,
 	repoBranches []community.RepoBranch,
 	repoOwners []string,
+	permission string,
 	log *logrus.Entry,
 ) ([]community.RepoBranch, []string) {
-	if err := bot.initRepoReviewer(org, repoName); err != nil {
+	if err := bot.initRepoReviewer(ctx, org, repoName); err != nil {
 		log.Errorf("initialize the reviewers, err:%s", err.Error())
 	}
 
@@ -92,12 +167,16 @@ func (bot *robot) initNewlyCreatedRepo(
 		{Name: community.BranchMaster},
 	}
 	for _, item := range repoBranches {
+		if isCancelled(ctx) {
+			break
+		}
+
 		if item.Name == community.BranchMaster {
 			if item.Type != community.BranchProtected {
 				continue
 			}
 
-			if err := bot.updateBranch(org, repoName, item.Name, true); err == nil {
+			if err := bot.updateBranch(ctx, org, repoName, item.Name, true); err == nil {
 				branches[0].Type = community.BranchProtected
 			} else {
 				log.WithFields(logrus.Fields{
@@ -106,7 +185,7 @@ func (bot *robot) initNewlyCreatedRepo(
 				}).Error(err)
 			}
 		} else {
-			if b, ok := bot.createBranch(org, repoName, item, log); ok {
+			if b, ok := bot.createBranch(ctx, org, repoName, item, log); ok {
 				branches = append(branches, b)
 			}
 		}
@@ -114,7 +193,11 @@ func (bot *robot) initNewlyCreatedRepo(
 
 	members := []string{}
 	for _, item := range repoOwners {
-		if err := bot.addRepoMember(org, repoName, item); err != nil {
+		if isCancelled(ctx) {
+			break
+		}
+
+		if err := bot.addRepoMember(ctx, org, repoName, item, permission); err != nil {
 			log.Errorf("add member:%s, err:%s", item, err)
 		} else {
 			members = append(members, item)
@@ -125,9 +208,10 @@ func (bot *robot) initNewlyCreatedRepo(
 }
 
 func (bot *robot) renameRepo(
+	ctx context.Context,
 	expectRepo expectRepoInfo,
 	log *logrus.Entry,
-	hook func(string, *logrus.Entry),
+	hooks repoHooks,
 ) models.RepoState {
 	org := expectRepo.org
 	oldRepo := expectRepo.expectRepoState.RenameFrom
@@ -136,41 +220,304 @@ func (bot *robot) renameRepo(
 	log = log.WithField("rename repo", fmt.Sprintf("from %s to %s", oldRepo, newRepo))
 	log.Info("start")
 
-	err := bot.cli.UpdateRepo(
+	source, found, err := bot.findRenameSource(ctx, &expectRepo)
+	if err != nil {
+		log.Errorf("find the repo which is renamed from, err:%s", err.Error())
+		expectRepo.result.fail(stepRename, err)
+
+		return models.RepoState{}
+	}
+
+	exists, err := bot.checkRepoExists(ctx, org, newRepo)
+	if err != nil {
+		log.Errorf("check whether the repo exists, err:%s", err.Error())
+		expectRepo.result.fail(stepRename, err)
+
+		return models.RepoState{}
+	}
+
+	// The target exists already. If the source exists too, the target is
+	// an unrelated repo, otherwise the repo has been renamed before.
+	if exists {
+		if found {
+			log.Errorf("both the target and the repo:%s which is renamed from exist", source)
+			expectRepo.result.failf(stepRename, "both the target and the repo:%s which is renamed from exist", source)
+
+			return models.RepoState{}
+		}
+
+		bot.completeRename(oldRepo, newRepo, log)
+
+		if s, b := bot.getRepoState(ctx, org, newRepo, log); b {
+			s.Branches = bot.handleBranch(ctx, expectRepo, s.Branches, log)
+			s.Members = bot.handleMember(ctx, expectRepo, s.Members, &s.Owner, log)
+			return s
+		}
+
+		return models.RepoState{}
+	}
+
+	if !found {
+		log.Errorf("neither the repo:%s nor the ones linked by the rename history exist", oldRepo)
+		expectRepo.result.failf(stepRename, "neither the repo:%s nor the ones linked by the rename history exist", oldRepo)
+
+		return models.RepoState{}
+	}
+
+	if source != oldRepo {
+		log.Infof("the repo:%s is renamed from %s according to the rename history", source, oldRepo)
+	}
+
+	err = bot.cli.UpdateRepo(
+		ctx,
 		org,
-		oldRepo,
+		source,
 		sdk.RepoPatchParam{
 			Name: newRepo,
 			Path: newRepo,
 		},
 	)
+	if err != nil {
+		log.Error(err)
+		expectRepo.result.fail(stepRename, err)
 
-	defer func(b bool) {
-		if b {
-			hook(newRepo, log)
-		}
-	}(err == nil)
+		return models.RepoState{}
+	}
+
+	bot.renames.markDone(source, newRepo, log)
+	bot.completeRename(oldRepo, newRepo, log)
+	bot.statuses.move(source, newRepo, log)
+	bot.templates.move(source, newRepo, log)
+	bot.failedHooks.move(source, newRepo, log)
 
-	// if the err == nil, invoke 'getRepoState' obviously.
-	// if the err != nil, it is better to call 'getRepoState' to
-	// avoid the case that the repo already exists.
-	if s, b := bot.getRepoState(org, newRepo, log); b {
-		s.Branches = bot.handleBranch(expectRepo, s.Branches, log)
-		s.Members = bot.handleMember(expectRepo, s.Members, &s.Owner, log)
+	bot.events.add(eventRepoRenamed, &expectRepo, source)
+
+	if source != oldRepo {
+		v := *expectRepo.expectRepoState
+		v.RenameFrom = source
+		expectRepo.expectRepoState = &v
+	}
+
+	defer func() {
+		bot.failedHooks.set(newRepo, hooks.runOnRename(ctx, expectRepo, log), log)
+	}()
+
+	if s, b := bot.getRepoState(ctx, org, newRepo, log); b {
+		s.Branches = bot.handleBranch(ctx, expectRepo, s.Branches, log)
+		s.Members = bot.handleMember(ctx, expectRepo, s.Members, &s.Owner, log)
 		return s
 	}
 
+	return models.RepoState{Available: true}
+}
+
+// transferRepo transfers the repo from another org and renames it if the name is changed.
+// The transferred repo is treated as a renamed one when running the hooks.
+func (bot *robot) transferRepo(
+	ctx context.Context,
+	expectRepo expectRepoInfo,
+	log *logrus.Entry,
+	hooks repoHooks,
+) models.RepoState {
+	org := expectRepo.org
+	repoName := expectRepo.getNewRepoName()
+	fromOrg, fromRepo := expectRepo.expectRepoState.GetTransferFrom()
+
+	log = log.WithField(
+		"transfer repo",
+		fmt.Sprintf("from %s/%s to %s/%s", fromOrg, fromRepo, org, repoName),
+	)
+	log.Info("start")
+
+	exists, err := bot.checkRepoExists(ctx, org, repoName)
 	if err != nil {
-		log.Error(err)
+		log.Errorf("check whether the repo exists, err:%s", err.Error())
+		expectRepo.result.fail(stepTransfer, err)
 
 		return models.RepoState{}
 	}
 
-	return models.RepoState{Available: true}
+	if !exists {
+		fromExists, err := bot.checkRepoExists(ctx, fromOrg, fromRepo)
+		if err != nil {
+			log.Errorf("check whether the repo which it is transferred from exists, err:%s", err.Error())
+			expectRepo.result.fail(stepTransfer, err)
+
+			return models.RepoState{}
+		}
+
+		transferred := false
+		if !fromExists {
+			if transferred, err = bot.isTransferredWithoutRename(ctx, &expectRepo); err != nil {
+				log.Errorf("check whether the repo has been transferred, err:%s", err.Error())
+				expectRepo.result.fail(stepTransfer, err)
+
+				return models.RepoState{}
+			}
+		}
+
+		switch {
+		case fromExists:
+			if err := bot.cli.TransferRepo(ctx, fromOrg, fromRepo, org); err != nil {
+				log.Errorf("transfer, err:%s", err.Error())
+				expectRepo.result.fail(stepTransfer, err)
+
+				return models.RepoState{}
+			}
+
+		case transferred:
+			// It was transferred, but failed to be renamed last time.
+			log.Infof("the repo has been transferred to %s/%s, resume renaming it", org, fromRepo)
+
+		default:
+			log.Errorf("neither the repo nor the one which it is transferred from exist")
+			expectRepo.result.failf(stepTransfer, "neither the repo nor the one which it is transferred from exist")
+
+			return models.RepoState{}
+		}
+
+		if fromRepo != repoName {
+			err = bot.cli.UpdateRepo(
+				ctx,
+				org,
+				fromRepo,
+				sdk.RepoPatchParam{
+					Name: repoName,
+					Path: repoName,
+				},
+			)
+			if err != nil {
+				log.Errorf("rename the transferred repo, err:%s", err.Error())
+				expectRepo.result.fail(stepTransfer, err)
+
+				return models.RepoState{}
+			}
+		}
+
+		log.Infof(
+			"transferring is complete, the transfer_from of %s can be removed from the repo file",
+			repoName,
+		)
+
+		bot.events.add(eventRepoTransferred, &expectRepo, expectRepo.expectRepoState.TransferFrom)
+
+		defer func() {
+			bot.failedHooks.set(repoName, hooks.runOnRename(ctx, expectRepo, log), log)
+		}()
+	}
+
+	s, b := bot.getRepoState(ctx, org, repoName, log)
+	if !b {
+		return models.RepoState{}
+	}
+
+	s.Branches = bot.handleBranch(ctx, expectRepo, s.Branches, log)
+	s.Members = bot.handleMember(ctx, expectRepo, s.Members, &s.Owner, log)
+	s.Property = bot.updateRepo(ctx, expectRepo, s.Property, log)
+
+	return s
 }
 
-func (bot *robot) getRepoState(org, repo string, log *logrus.Entry) (models.RepoState, bool) {
-	newRepo, err := bot.cli.GetRepo(org, repo)
+// isRenamePending returns true if the repo is declared to be renamed
+// from another one and the rename has not been done.
+func (bot *robot) isRenamePending(repo *community.Repository) bool {
+	oldRepo := repo.RenameFrom
+
+	return oldRepo != "" && oldRepo != repo.Name && !bot.renames.isDone(oldRepo, repo.Name)
+}
+
+// isTransferredWithoutRename checks whether the repo has been transferred with
+// the old name. The repo with the old name is unrelated if it is in the repo file.
+func (bot *robot) isTransferredWithoutRename(ctx context.Context, expectRepo *expectRepoInfo) (bool, error) {
+	_, fromRepo := expectRepo.expectRepoState.GetTransferFrom()
+	if fromRepo == expectRepo.getNewRepoName() {
+		return false, nil
+	}
+
+	if _, ok := expectRepo.allExpectRepos[fromRepo]; ok {
+		return false, nil
+	}
+
+	return bot.checkRepoExists(ctx, expectRepo.org, fromRepo)
+}
+
+// checkRenamed checks the existing repo whose rename is pending. It returns
+// an error if the one it is renamed from still exists, which means the existing
+// repo is an unrelated one and it should not be touched. It also returns an error
+// if it can't be checked.
+func (bot *robot) checkRenamed(ctx context.Context, expectRepo *expectRepoInfo, log *logrus.Entry) error {
+	repo := expectRepo.expectRepoState
+	oldRepo := repo.RenameFrom
+
+	source, found, err := bot.findRenameSource(ctx, expectRepo)
+	if err != nil {
+		return fmt.Errorf("find the repo which %s is renamed from, err:%s", repo.Name, err.Error())
+	}
+
+	if found {
+		return fmt.Errorf(
+			"the repo:%s exists, but the repo:%s which it is renamed from exists too",
+			repo.Name, source,
+		)
+	}
+
+	bot.completeRename(oldRepo, repo.Name, log)
+
+	return nil
+}
+
+// findRenameSource finds the existing repo which should be renamed to the expect repo.
+// The repos which the rename_from is renamed from will be tried if the rename_from does
+// not exist, but the chain stops at the ones which are in the repo file.
+// It returns an error if the existence of any candidate can't be checked.
+func (bot *robot) findRenameSource(ctx context.Context, expectRepo *expectRepoInfo) (string, bool, error) {
+	org := expectRepo.org
+	repo := expectRepo.expectRepoState.RenameFrom
+
+	if b, err := bot.checkRepoExists(ctx, org, repo); err != nil || b {
+		return repo, b, err
+	}
+
+	inRepoFile := func(name string) bool {
+		_, ok := expectRepo.allExpectRepos[name]
+		return ok
+	}
+
+	for _, item := range bot.renames.candidates(repo, expectRepo.getNewRepoName(), inRepoFile) {
+		if b, err := bot.checkRepoExists(ctx, org, item); err != nil || b {
+			return item, b, err
+		}
+	}
+
+	return "", false, nil
+}
+
+func (bot *robot) completeRename(from, to string, log *logrus.Entry) {
+	if bot.renames.markDone(from, to, log) {
+		log.Infof(
+			"renaming repo from %s to %s is complete, the rename_from of %s can be removed from the repo file",
+			from, to, to,
+		)
+	}
+}
+
+// checkRepoExists returns false only if the repo is not found.
+// Any other error is returned, because the existence is unknown.
+func (bot *robot) checkRepoExists(ctx context.Context, org, repo string) (bool, error) {
+	_, err := bot.cli.GetRepo(ctx, org, repo)
+	if err == nil {
+		return true, nil
+	}
+
+	if isNotFound(err) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func (bot *robot) getRepoState(ctx context.Context, org, repo string, log *logrus.Entry) (models.RepoState, bool) {
+	newRepo, err := bot.cli.GetRepo(ctx, org, repo)
 	if err != nil {
 		log.Errorf("get repo, err:%s", err.Error())
 
@@ -187,7 +534,7 @@ func (bot *robot) getRepoState(org, repo string, log *logrus.Entry) (models.Repo
 		Owner: newRepo.Owner.Login,
 	}
 
-	branches, err := bot.listAllBranchOfRepo(org, repo)
+	branches, err := bot.listAllBranchOfRepo(ctx, org, repo)
 	if err != nil {
 		log.Errorf("list branch, err:%s", err.Error())
 	} else {
@@ -197,8 +544,9 @@ func (bot *robot) getRepoState(org, repo string, log *logrus.Entry) (models.Repo
 	return r, true
 }
 
-func (bot *robot) initRepoReviewer(org, repo string) error {
+func (bot *robot) initRepoReviewer(ctx context.Context, org, repo string) error {
 	return bot.cli.SetRepoReviewer(
+		ctx,
 		org,
 		repo,
 		sdk.SetRepoReviewer{
@@ -210,7 +558,16 @@ func (bot *robot) initRepoReviewer(org, repo string) error {
 	)
 }
 
-func (bot *robot) updateRepo(expectRepo expectRepoInfo, lp models.RepoProperty, log *logrus.Entry) models.RepoProperty {
+func (bot *robot) updateRepo(
+	ctx context.Context,
+	expectRepo expectRepoInfo,
+	lp models.RepoProperty,
+	log *logrus.Entry,
+) models.RepoProperty {
+	if isCancelled(ctx) {
+		return lp
+	}
+
 	org := expectRepo.org
 	repo := expectRepo.expectRepoState
 	repoName := expectRepo.getNewRepoName()
@@ -222,6 +579,7 @@ func (bot *robot) updateRepo(expectRepo expectRepoInfo, lp models.RepoProperty,
 		log.Info("start")
 
 		err := bot.cli.UpdateRepo(
+			ctx,
 			org,
 			repoName,
 			sdk.RepoPatchParam{
@@ -241,6 +599,170 @@ func (bot *robot) updateRepo(expectRepo expectRepoInfo, lp models.RepoProperty,
 			"Private":    ep,
 			"CanComment": ec,
 		}).Error(err)
+		expectRepo.result.fail(stepProperty, err)
 	}
 	return lp
 }
+
+// handleRemovedRepos finds out the repos which are removed from the repo file since last check.
+// At the first check, the repos are compared with the ones at the commit checked before restarting.
+func (bot *robot) handleRemovedRepos(ctx context.Context, org string, local *localState, expect *expectState) {
+	repos := expect.getRepos()
+	if len(repos) == 0 {
+		return
+	}
+
+	log := expect.log
+
+	last := bot.expectedRepos
+	if last == nil {
+		last = bot.loadCheckpointRepos(ctx, org, expect)
+	}
+
+	sigOfRepo := make(map[string]string)
+	for name, sig := range expect.sigs {
+		for _, k := range sig.repos {
+			sigOfRepo[k] = name
+		}
+		for _, k := range sig.frozenRepos {
+			sigOfRepo[k] = name
+		}
+	}
+
+	current := genExpectedRepos(org, repos, func(repo string) string {
+		sig, ok := sigOfRepo[repo]
+		if e, ok1 := last[repo]; !ok && ok1 {
+			// the sig may not be checked completely.
+			sig = e.sig
+		}
+
+		return sig
+	})
+
+	bot.expectedRepos = current
+	bot.saveCheckpoint(expect.commit, local, log)
+
+	if last == nil {
+		return
+	}
+
+	transferredOut := expect.transferredOut
+
+	removed := make(map[string]*expectRepoInfo)
+	for k, v := range last {
+		if _, ok := current[k]; ok {
+			continue
+		}
+
+		if t, ok := transferredOut[k]; ok {
+			log.Infof("repo:%s is removed from the repo file, because it is transferred to %s", k, t)
+			continue
+		}
+
+		removed[k] = v
+	}
+
+	if len(removed) > 0 {
+		log.Infof("%d repos are removed from the repo file", len(removed))
+
+		for k := range removed {
+			bot.events.addEvent(changeEvent{Kind: eventRepoRemoved, Repo: k})
+		}
+
+		bot.removeOBSMetaProjects(ctx, removed, log)
+	}
+}
+
+func genExpectedRepos(
+	org string,
+	repos map[string]*community.Repository,
+	sigOf func(string) string,
+) map[string]*expectRepoInfo {
+	r := make(map[string]*expectRepoInfo)
+	for k, v := range repos {
+		e := newExpectRepoInfo(org, v, sigOf(k), community.EffectiveOwners{}, repos)
+		r[k] = e
+
+		// the old repo will be handled when renaming.
+		if v.RenameFrom != "" {
+			r[v.RenameFrom] = e
+		}
+	}
+
+	return r
+}
+
+// loadCheckpointRepos loads the repos in the repo file at the commit checked before restarting,
+// so that the repos removed while the bot was down can be found.
+func (bot *robot) loadCheckpointRepos(ctx context.Context, org string, expect *expectState) map[string]*expectRepoInfo {
+	var c checkpoint
+	if err := loadJSONFile(bot.cfg.CheckpointFile, &c); err != nil {
+		expect.log.Errorf("read the checkpoint, err:%s", err.Error())
+
+		return nil
+	}
+
+	sha := c.Commit
+	if sha == "" {
+		return nil
+	}
+
+	repos, err := bot.loadReposAt(ctx, &expect.w, expect.repos.wf.file, sha)
+	if err != nil {
+		expect.log.Errorf("load the repo file at the checkpoint:%s, err:%s", sha, err.Error())
+
+		return nil
+	}
+
+	sigOfRepo := make(map[string]string)
+
+	sigs := new(community.Sigs)
+	if err := bot.loadFileAt(ctx, &expect.w, expect.sig.wf.file, sha, sigs); err != nil {
+		expect.log.Errorf("load the sig file at the checkpoint:%s, err:%s", sha, err.Error())
+	} else {
+		for _, sig := range sigs.GetSigs() {
+			for _, k := range sig.GetRepos(org) {
+				sigOfRepo[k] = sig.Name
+			}
+		}
+	}
+
+	return genExpectedRepos(org, repos, func(repo string) string {
+		return sigOfRepo[repo]
+	})
+}
+
+// checkpoint is the state persisted after each check.
+type checkpoint struct {
+	// Commit is the commit of community repo which has been checked.
+	Commit string `json:"commit"`
+
+	// Results are the results of handling the repos, so that they can be
+	// reported by the report command and after restarting.
+	Results map[string]models.RepoResult `json:"results,omitempty"`
+}
+
+// saveCheckpoint records the commit of community repo which has been checked
+// and the results of the repos which have been handled.
+func (bot *robot) saveCheckpoint(sha string, local *localState, log *logrus.Entry) {
+	if sha == "" {
+		return
+	}
+
+	c := checkpoint{Commit: sha, Results: local.results()}
+	if err := saveJSONFile(bot.cfg.CheckpointFile, c); err != nil {
+		log.Errorf("save the checkpoint, err:%s", err.Error())
+	}
+}
+
+// restoreResults restores the results of repos handled before from the checkpoint.
+func (bot *robot) restoreResults(local *localState, log *logrus.Entry) {
+	var c checkpoint
+	if err := loadJSONFile(bot.cfg.CheckpointFile, &c); err != nil {
+		log.Errorf("read the checkpoint, err:%s", err.Error())
+
+		return
+	}
+
+	local.restoreResults(c.Results)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+// repoStatusBanners are the banners added to the description of repo for each status.
+var repoStatusBanners = map[string]string{
+	community.RepoStatusDeprecated: "[Deprecated] ",
+	community.RepoStatusArchived:   "[Archived] ",
+}
+
+// statusPolicy returns the status whose policy will be applied.
+// The incubating and active repos share the normal policy.
+func statusPolicy(status string) string {
+	if _, ok := repoStatusBanners[status]; ok {
+		return status
+	}
+
+	return community.RepoStatusActive
+}
+
+func trimStatusBanner(desc string) string {
+	for _, v := range repoStatusBanners {
+		desc = strings.TrimPrefix(desc, v)
+	}
+
+	return desc
+}
+
+// getExpectBranches returns the expected branches. All of them
+// are protected if the repo is deprecated or archived.
+func (e *expectRepoInfo) getExpectBranches() []community.RepoBranch {
+	b := e.expectRepoState.Branches
+	if statusPolicy(e.expectRepoState.GetStatus()) == community.RepoStatusActive {
+		return b
+	}
+
+	r := make([]community.RepoBranch, len(b))
+	for i := range b {
+		r[i] = b[i]
+		r[i].Type = community.BranchProtected
+	}
+
+	return r
+}
+
+// getMemberPermission returns the permission of members. The archived repo is read-only.
+func (e *expectRepoInfo) getMemberPermission() string {
+	if e.expectRepoState.GetStatus() == community.RepoStatusArchived {
+		return "pull"
+	}
+
+	return "push"
+}
+
+// handleRepoStatus applies the policy of lifecycle status when the status changes.
+// The applied status is recorded in the status history, and it is kept as before
+// if failed, so that it will be retried next time.
+func (bot *robot) handleRepoStatus(
+	ctx context.Context,
+	expectRepo *expectRepoInfo,
+	state *models.RepoState,
+	log *logrus.Entry,
+) {
+	repo := expectRepo.getNewRepoName()
+	record := bot.statuses.get(repo)
+
+	status := statusPolicy(expectRepo.expectRepoState.GetStatus())
+	applied := statusPolicy(record.Status)
+
+	if status == applied || isCancelled(ctx) {
+		return
+	}
+
+	org := expectRepo.org
+
+	l := log.WithField("apply status", fmt.Sprintf("%s:%s", repo, status))
+	l.Info("start")
+
+	done := true
+
+	if err := bot.updateRepoForStatus(ctx, org, repo, status, applied); err != nil {
+		l.Error(err)
+		expectRepo.result.fail(stepStatus, err)
+
+		done = false
+	}
+
+	// The branches in the repo file are handled by handleBranch.
+	var ok bool
+	if status == community.RepoStatusActive {
+		record.ProtectedBranches, ok = bot.unprotectBranches(ctx, expectRepo, record.ProtectedBranches, l)
+	} else {
+		record.ProtectedBranches, ok = bot.protectAllBranches(ctx, expectRepo, record.ProtectedBranches, l)
+	}
+	done = done && ok
+
+	if status == community.RepoStatusArchived || applied == community.RepoStatusArchived {
+		if !bot.setMembersPermission(ctx, expectRepo, state, l) {
+			done = false
+		}
+	}
+
+	if done {
+		record.Status = status
+	}
+
+	// The branches protected are recorded even if failed.
+	bot.statuses.set(repo, record, l)
+
+	if done {
+		bot.events.add(eventStatusChanged, expectRepo, status)
+	}
+}
+
+// updateRepoForStatus replaces the banner in the description, and disables
+// the issues of archived repo or enables them when it is reactivated.
+func (bot *robot) updateRepoForStatus(ctx context.Context, org, repo, status, applied string) error {
+	v, err := bot.cli.GetRepo(ctx, org, repo)
+	if err != nil {
+		return err
+	}
+
+	param := sdk.RepoPatchParam{
+		Name:        repo,
+		Description: repoStatusBanners[status] + trimStatusBanner(v.Description),
+	}
+
+	if status == community.RepoStatusArchived {
+		param.HasIssues = "false"
+	} else if applied == community.RepoStatusArchived {
+		param.HasIssues = "true"
+	}
+
+	return bot.cli.UpdateRepo(ctx, org, repo, param)
+}
+
+// protectAllBranches protects all the branches including the ones not in the repo file.
+// It returns the protected ones which are not in the repo file, including the ones
+// protected before, so that they can be unprotected when the repo is reactivated.
+func (bot *robot) protectAllBranches(
+	ctx context.Context,
+	expectRepo *expectRepoInfo,
+	protected []string,
+	log *logrus.Entry,
+) ([]string, bool) {
+	org := expectRepo.org
+	repo := expectRepo.getNewRepoName()
+
+	branches, err := bot.listAllBranchOfRepo(ctx, org, repo)
+	if err != nil {
+		log.Errorf("list all branch of repo:%s, err:%s", repo, err.Error())
+		expectRepo.result.fail(stepStatus, err)
+
+		return protected, false
+	}
+
+	inRepoFile := sets.NewString(branchNames(expectRepo.expectRepoState.Branches)...)
+	r := sets.NewString(protected...)
+
+	for i := range branches {
+		item := &branches[i]
+		if item.Type == community.BranchProtected {
+			continue
+		}
+
+		if isCancelled(ctx) {
+			return r.List(), false
+		}
+
+		if err := bot.updateBranch(ctx, org, repo, item.Name, true); err != nil {
+			log.Errorf("protect branch:%s, err:%s", item.Name, err.Error())
+			expectRepo.result.failf(stepStatus, "protect branch:%s, err:%s", item.Name, err.Error())
+
+			return r.List(), false
+		}
+
+		if !inRepoFile.Has(item.Name) {
+			r.Insert(item.Name)
+		}
+	}
+
+	return r.List(), true
+}
+
+// unprotectBranches unprotects the branches protected by the policy of status.
+// It returns the ones which failed to be unprotected.
+func (bot *robot) unprotectBranches(
+	ctx context.Context,
+	expectRepo *expectRepoInfo,
+	protected []string,
+	log *logrus.Entry,
+) ([]string, bool) {
+	org := expectRepo.org
+	repo := expectRepo.getNewRepoName()
+	expect := genBranchSets(expectRepo.expectRepoState.Branches)
+
+	r := []string{}
+	for i, name := range protected {
+		if isCancelled(ctx) {
+			return append(r, protected[i:]...), false
+		}
+
+		// It is protected in the repo file now.
+		if b := expect.get(name); b != nil && b.Type == community.BranchProtected {
+			continue
+		}
+
+		err := bot.updateBranch(ctx, org, repo, name, false)
+		if err != nil && !isNotFound(err) {
+			log.Errorf("unprotect branch:%s, err:%s", name, err.Error())
+			expectRepo.result.failf(stepStatus, "unprotect branch:%s, err:%s", name, err.Error())
+
+			r = append(r, name)
+		}
+	}
+
+	return r, len(r) == 0
+}
+
+// setMembersPermission downgrades all the members to pull when the repo is archived,
+// and restores the permission of owners when it is reactivated.
+func (bot *robot) setMembersPermission(
+	ctx context.Context,
+	expectRepo *expectRepoInfo,
+	state *models.RepoState,
+	log *logrus.Entry,
+) bool {
+	org := expectRepo.org
+	repo := expectRepo.getNewRepoName()
+	permission := expectRepo.getMemberPermission()
+
+	members := expectRepo.expectOwners
+	if permission == "pull" {
+		members = state.Members
+	}
+
+	done := true
+	for _, k := range members {
+		if k == state.Owner {
+			continue
+		}
+
+		if isCancelled(ctx) {
+			return false
+		}
+
+		if err := bot.addRepoMember(ctx, org, repo, k, permission); err != nil {
+			log.Errorf("set the permission of member:%s to %s, err:%s", k, permission, err.Error())
+			expectRepo.result.failf(stepStatus, "set the permission of member:%s, err:%s", k, err.Error())
+
+			done = false
+		}
+	}
+
+	return done
+}
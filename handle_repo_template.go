@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
+
+// applyRepoTemplate seeds the new repo from the template repo. The repo is recorded
+// as pending if some files are not committed, and it will be retried next time.
+func (bot *robot) applyRepoTemplate(ctx context.Context, expectRepo *expectRepoInfo, log *logrus.Entry) {
+	done := bot.seedRepo(ctx, expectRepo, log)
+
+	bot.templates.set(expectRepo.getNewRepoName(), !done, log)
+}
+
+// seedRepo commits the files of template repo to the master branch of new repo.
+// The file which exists in the new repo will not be overwritten.
+// It returns false if some files are not committed.
+func (bot *robot) seedRepo(ctx context.Context, expectRepo *expectRepoInfo, log *logrus.Entry) bool {
+	cfg := &bot.cfg.RepoTemplate
+
+	tOrg, tRepo := cfg.get(expectRepo.sig, expectRepo.expectRepoState.Type)
+	if tOrg == "" {
+		return true
+	}
+
+	files, err := bot.listRepoFiles(ctx, tOrg, tRepo, cfg.Branch)
+	if err != nil {
+		log.Errorf("list files of template repo:%s/%s, err:%s", tOrg, tRepo, err.Error())
+		expectRepo.result.fail(stepTemplate, err)
+
+		return false
+	}
+
+	org := expectRepo.org
+	repo := expectRepo.getNewRepoName()
+
+	exists, err := bot.listRepoFiles(ctx, org, repo, community.BranchMaster)
+	if err != nil {
+		log.Errorf("list files of repo:%s, err:%s", repo, err.Error())
+		expectRepo.result.fail(stepTemplate, err)
+
+		return false
+	}
+
+	data := newRepoTemplateData(expectRepo, community.BranchMaster)
+	msg := fmt.Sprintf("seed repo:%s/%s from the template repo:%s/%s", org, repo, tOrg, tRepo)
+
+	done := true
+	for _, f := range files.List() {
+		if isCancelled(ctx) {
+			return false
+		}
+
+		isTemplate := strings.HasSuffix(f, cfg.Suffix)
+
+		p := f
+		if isTemplate {
+			v, err := renderRepoTemplate(f, strings.TrimSuffix(f, cfg.Suffix), &data)
+			if err != nil {
+				log.Errorf("render the path of template file:%s, err:%s", f, err.Error())
+				expectRepo.result.failf(stepTemplate, "render the path of template file:%s, err:%s", f, err.Error())
+
+				done = false
+				continue
+			}
+
+			p = v
+		}
+
+		if exists.Has(p) {
+			continue
+		}
+
+		c, err := bot.cli.GetPathContent(ctx, tOrg, tRepo, f, cfg.Branch)
+		if err != nil {
+			log.Errorf("get content of template file:%s, err:%s", f, err.Error())
+			expectRepo.result.fail(stepTemplate, err)
+
+			done = false
+			continue
+		}
+
+		v, err := base64.StdEncoding.DecodeString(c.Content)
+		if err != nil {
+			log.Errorf("decode content of template file:%s, err:%s", f, err.Error())
+			expectRepo.result.fail(stepTemplate, err)
+
+			done = false
+			continue
+		}
+
+		content := string(v)
+		if isTemplate {
+			if content, err = renderRepoTemplate(f, content, &data); err != nil {
+				log.Errorf("render template file:%s, err:%s", f, err.Error())
+				expectRepo.result.failf(stepTemplate, "render template file:%s, err:%s", f, err.Error())
+
+				done = false
+				continue
+			}
+		}
+
+		if _, err := bot.cli.CreateFile(ctx, org, repo, community.BranchMaster, p, content, msg); err != nil {
+			log.Errorf("seed file:%s, err:%s", p, err.Error())
+			expectRepo.result.fail(stepTemplate, err)
+
+			done = false
+		}
+	}
+
+	return done
+}
+
+// listRepoFiles returns the paths of all the files on the branch.
+func (bot *robot) listRepoFiles(ctx context.Context, org, repo, branch string) (sets.String, error) {
+	v, err := bot.cli.GetDirectoryTree(ctx, org, repo, branch, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	r := sets.NewString()
+	for i := range v.Tree {
+		if item := &v.Tree[i]; item.Type == "blob" {
+			r.Insert(item.Path)
+		}
+	}
+
+	return r, nil
+}
+
+// renderRepoTemplate renders the text with the data of repo.
+func renderRepoTemplate(name, text string, data *repoTemplateData) (string, error) {
+	t, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	return execTemplate(t, data)
+}
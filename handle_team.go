@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// sigTeamState is the members and repos of team which have been synced.
+type sigTeamState struct {
+	members sets.String
+	repos   sets.String
+
+	// loadTime is when the members and repos are listed.
+	loadTime time.Time
+}
+
+// handleSigTeams syncs each sig to a team. The team of sig which is removed will be kept.
+func (bot *robot) handleSigTeams(ctx context.Context, org string, sigs map[string]expectSig, log *logrus.Entry) {
+	cfg := &bot.cfg.SigTeam
+	if !cfg.Enable || len(sigs) == 0 {
+		return
+	}
+
+	if bot.sigTeams == nil {
+		bot.sigTeams = make(map[string]*sigTeamState)
+	}
+
+	names := make([]string, 0, len(sigs))
+	for k := range sigs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var teams sets.String
+	for _, sig := range names {
+		if isCancelled(ctx) {
+			break
+		}
+
+		name := cfg.teamName(sig)
+		l := log.WithField("team", name)
+
+		item := sigs[sig]
+		if item.frozen != "" {
+			l.Infof("skip syncing the team, because %s", item.frozen)
+			continue
+		}
+
+		s, ok := bot.sigTeams[name]
+		if !ok || time.Since(s.loadTime) >= cfg.resyncInterval() {
+			if teams == nil {
+				v, err := bot.listTeams(ctx, org)
+				if err != nil {
+					log.Errorf("list teams, err:%s", err.Error())
+					return
+				}
+				teams = v
+			}
+
+			v, err := bot.loadSigTeam(ctx, org, name, sig, teams.Has(name))
+			if err != nil {
+				l.Error(err)
+				delete(bot.sigTeams, name)
+				continue
+			}
+
+			s = v
+			bot.sigTeams[name] = s
+		}
+
+		bot.syncTeamMembers(ctx, org, name, s, item.maintainers, l)
+		bot.syncTeamRepos(ctx, org, name, s, item.repos, item.frozenRepos, l)
+	}
+}
+
+func (bot *robot) listTeams(ctx context.Context, org string) (sets.String, error) {
+	v, err := bot.cli.ListTeams(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+
+	r := sets.NewString()
+	for i := range v {
+		r.Insert(v[i].Name)
+	}
+
+	return r, nil
+}
+
+func (bot *robot) loadSigTeam(ctx context.Context, org, name, sig string, exists bool) (*sigTeamState, error) {
+	if !exists {
+		if err := bot.cli.CreateTeam(ctx, org, name, fmt.Sprintf("the team of sig:%s", sig)); err != nil {
+			return nil, fmt.Errorf("create team, err:%s", err.Error())
+		}
+
+		return &sigTeamState{
+			members:  sets.NewString(),
+			repos:    sets.NewString(),
+			loadTime: time.Now(),
+		}, nil
+	}
+
+	members, err := bot.cli.ListTeamMembers(ctx, org, name)
+	if err != nil {
+		return nil, fmt.Errorf("list team members, err:%s", err.Error())
+	}
+
+	repos, err := bot.cli.ListTeamRepos(ctx, org, name)
+	if err != nil {
+		return nil, fmt.Errorf("list team repos, err:%s", err.Error())
+	}
+
+	return &sigTeamState{
+		members:  sets.NewString(toLowerOfMembers(members)...),
+		repos:    sets.NewString(repos...),
+		loadTime: time.Now(),
+	}, nil
+}
+
+func (bot *robot) syncTeamMembers(ctx context.Context, org, name string, s *sigTeamState, expect []string, log *logrus.Entry) {
+	e := sets.NewString(expect...)
+
+	for _, k := range e.Difference(s.members).List() {
+		if err := bot.cli.AddTeamMember(ctx, org, name, k); err != nil {
+			log.Errorf("add team member:%s, err:%s", k, err.Error())
+		} else {
+			s.members.Insert(k)
+		}
+	}
+
+	for _, k := range s.members.Difference(e).List() {
+		if err := bot.cli.RemoveTeamMember(ctx, org, name, k); err != nil {
+			log.Errorf("remove team member:%s, err:%s", k, err.Error())
+		} else {
+			s.members.Delete(k)
+		}
+	}
+}
+
+// syncTeamRepos grants the permission of expected repos to the team and revokes the others.
+// The frozen repos are kept as they are.
+func (bot *robot) syncTeamRepos(
+	ctx context.Context,
+	org, name string,
+	s *sigTeamState,
+	expect, frozen []string,
+	log *logrus.Entry,
+) {
+	e := sets.NewString(expect...)
+
+	for _, k := range e.Difference(s.repos).List() {
+		if err := bot.cli.AddTeamRepo(ctx, org, name, k, bot.cfg.SigTeam.Permission); err != nil {
+			log.Errorf("grant the permission of repo:%s, err:%s", k, err.Error())
+		} else {
+			s.repos.Insert(k)
+		}
+	}
+
+	for _, k := range s.repos.Difference(e.Insert(frozen...)).List() {
+		if err := bot.cli.RemoveTeamRepo(ctx, org, name, k); err != nil {
+			log.Errorf("revoke the permission of repo:%s, err:%s", k, err.Error())
+		} else {
+			s.repos.Delete(k)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// hookFailures records the hooks which failed or were not run for each repo,
+// so that they can be run again when the repo is handled next time.
+type hookFailures struct {
+	lock  sync.Mutex
+	file  string
+	hooks map[string][]string
+}
+
+// load loads the failures from the file. They are only kept in memory if file is empty.
+func (h *hookFailures) load(file string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.file = file
+	h.hooks = make(map[string][]string)
+
+	return loadJSONFile(file, &h.hooks)
+}
+
+func (h *hookFailures) get(repo string) []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.hooks[repo]
+}
+
+// set records the failed hooks of repo. The record is removed if there are none.
+func (h *hookFailures) set(repo string, hooks []string, log *logrus.Entry) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.hooks == nil {
+		h.hooks = make(map[string][]string)
+	}
+
+	if len(hooks) == 0 {
+		if _, ok := h.hooks[repo]; !ok {
+			return
+		}
+
+		delete(h.hooks, repo)
+	} else {
+		h.hooks[repo] = hooks
+	}
+
+	h.save(log)
+}
+
+// move moves the record when the repo is renamed.
+func (h *hookFailures) move(from, to string, log *logrus.Entry) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	v, ok := h.hooks[from]
+	if !ok {
+		return
+	}
+
+	delete(h.hooks, from)
+	h.hooks[to] = v
+
+	h.save(log)
+}
+
+func (h *hookFailures) save(log *logrus.Entry) {
+	if err := saveJSONFile(h.file, h.hooks); err != nil {
+		log.Errorf("save the failed hooks, err:%s", err.Error())
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// loadJSONFile decodes the file to v. It does nothing if the file is
+// not set, does not exist or is empty, so that the data is only kept in memory.
+func loadJSONFile(file string, v interface{}) error {
+	if file == "" {
+		return nil
+	}
+
+	c, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if len(c) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(c, v)
+}
+
+// saveJSONFile writes v to a temporary file and renames it to the file, so
+// that the file is complete even if exiting when writing. It does nothing if
+// the file is not set.
+func saveJSONFile(file string, v interface{}) error {
+	if file == "" {
+		return nil
+	}
+
+	c, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, c, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
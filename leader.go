@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const lockTypeFile = "file"
+
+// leaderLock is the resource which the replicas compete for.
+type leaderLock interface {
+	// tryAcquireOrRenew acquires the lock if it is free or expired,
+	// or renews it if it is held by the identity already.
+	tryAcquireOrRenew(identity string, leaseDuration time.Duration) (bool, error)
+
+	// release frees the lock if it is held by the identity.
+	release(identity string) error
+}
+
+func newLeaderLock(cfg *leaderElection) (leaderLock, error) {
+	switch cfg.LockType {
+	case lockTypeFile:
+		return &fileLock{path: cfg.LockFile}, nil
+	default:
+		return nil, fmt.Errorf("unsupported lock type:%s", cfg.LockType)
+	}
+}
+
+type leaderElector struct {
+	cfg  *leaderElection
+	lock leaderLock
+	log  *logrus.Entry
+}
+
+// run blocks until the ctx is done. It invokes lead each time becoming the leader
+// and the ctx passed to lead will be done when losing the leadership.
+// standby is invoked periodically when it is not the leader.
+func (l *leaderElector) run(ctx context.Context, lead func(context.Context), standby func()) {
+	for {
+		if !l.acquire(ctx, standby) {
+			return
+		}
+
+		l.log.Infof("%s becomes the leader", l.cfg.Identity)
+
+		leaderCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			l.renew(leaderCtx, cancel)
+		}()
+
+		lead(leaderCtx)
+
+		cancel()
+		<-done
+
+		if err := l.lock.release(l.cfg.Identity); err != nil {
+			l.log.Errorf("release the leadership, err:%s", err.Error())
+		}
+
+		l.log.Infof("%s stops leading", l.cfg.Identity)
+	}
+}
+
+// acquire returns false if the ctx is done before acquiring the leadership.
+func (l *leaderElector) acquire(ctx context.Context, standby func()) bool {
+	for {
+		if isCancelled(ctx) {
+			return false
+		}
+
+		b, err := l.lock.tryAcquireOrRenew(l.cfg.Identity, l.cfg.leaseDuration())
+		if err != nil {
+			l.log.Errorf("acquire the leadership, err:%s", err.Error())
+		}
+		if b {
+			return true
+		}
+
+		standby()
+
+		sleep(ctx, l.cfg.retryPeriod())
+	}
+}
+
+// renew keeps the leadership until the ctx is done or failing to
+// renew within the renew deadline, in which case the cancel is invoked.
+func (l *leaderElector) renew(ctx context.Context, cancel context.CancelFunc) {
+	last := time.Now()
+
+	for {
+		sleep(ctx, l.cfg.retryPeriod())
+
+		if isCancelled(ctx) {
+			return
+		}
+
+		b, err := l.lock.tryAcquireOrRenew(l.cfg.Identity, l.cfg.leaseDuration())
+		if err != nil {
+			l.log.Errorf("renew the leadership, err:%s", err.Error())
+		}
+
+		if b {
+			last = time.Now()
+			continue
+		}
+
+		// err == nil means the lock is held by others already.
+		if err != nil && time.Since(last) < l.cfg.renewDeadline() {
+			continue
+		}
+
+		l.log.Errorf("%s lost the leadership", l.cfg.Identity)
+
+		cancel()
+
+		return
+	}
+}
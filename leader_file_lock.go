@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+)
+
+type leaseRecord struct {
+	Holder    string    `json:"holder"`
+	RenewTime time.Time `json:"renew_time"`
+
+	// LeaseDuration is in second.
+	LeaseDuration int `json:"lease_duration"`
+}
+
+func (r *leaseRecord) equal(v *leaseRecord) bool {
+	return r.Holder == v.Holder && r.RenewTime.Equal(v.RenewTime) && r.LeaseDuration == v.LeaseDuration
+}
+
+// isHeldByOthers checks whether the lease is held by others. The observedTime is when
+// the record was seen to be changed by the local clock, so that the lease doesn't
+// depend on the clock of the holder.
+func (r *leaseRecord) isHeldByOthers(identity string, observedTime, now time.Time) bool {
+	if r.Holder == "" || r.Holder == identity {
+		return false
+	}
+
+	return now.Before(observedTime.Add(time.Duration(r.LeaseDuration) * time.Second))
+}
+
+// fileLock is the lock based on a file shared by the replicas, such as a file on
+// the shared volume. The lease record is saved in the file and it is updated under
+// the protection of flock.
+type fileLock struct {
+	path string
+
+	// observed is the record read last time and observedTime is when it was
+	// changed by the local clock. The holder changes the RenewTime each time
+	// renewing, so the lease expires if the record is not changed within the duration.
+	observed     leaseRecord
+	observedTime time.Time
+}
+
+func (l *fileLock) tryAcquireOrRenew(identity string, leaseDuration time.Duration) (bool, error) {
+	b := false
+
+	err := l.update(func(r *leaseRecord) bool {
+		now := time.Now()
+		if !r.equal(&l.observed) {
+			l.observed = *r
+			l.observedTime = now
+		}
+
+		if r.isHeldByOthers(identity, l.observedTime, now) {
+			return false
+		}
+
+		*r = leaseRecord{
+			Holder:        identity,
+			RenewTime:     now,
+			LeaseDuration: int(leaseDuration / time.Second),
+		}
+		l.observed = *r
+		l.observedTime = now
+		b = true
+
+		return true
+	})
+
+	return b && err == nil, err
+}
+
+func (l *fileLock) release(identity string) error {
+	return l.update(func(r *leaseRecord) bool {
+		if r.Holder != identity {
+			return false
+		}
+
+		*r = leaseRecord{}
+
+		return true
+	})
+}
+
+// update reads the lease record and writes it back if f returns true.
+func (l *fileLock) update(f func(*leaseRecord) bool) error {
+	fi, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	fd := int(fi.Fd())
+	if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(fd, syscall.LOCK_UN)
+
+	c, err := ioutil.ReadAll(fi)
+	if err != nil {
+		return err
+	}
+
+	r := leaseRecord{}
+	if len(c) > 0 {
+		if err := json.Unmarshal(c, &r); err != nil {
+			return err
+		}
+	}
+
+	if !f(&r) {
+		return nil
+	}
+
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if err := fi.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := fi.WriteAt(v, 0); err != nil {
+		return err
+	}
+
+	return fi.Sync()
+}
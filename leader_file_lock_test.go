@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaseRecordIsHeldByOthers(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name         string
+		record       leaseRecord
+		observedTime time.Time
+		expect       bool
+	}{
+		{
+			name:         "no holder",
+			record:       leaseRecord{LeaseDuration: 15},
+			observedTime: now,
+		},
+		{
+			name:         "held by self",
+			record:       leaseRecord{Holder: "me", LeaseDuration: 15},
+			observedTime: now,
+		},
+		{
+			name:         "held by others",
+			record:       leaseRecord{Holder: "other", LeaseDuration: 15},
+			observedTime: now.Add(-10 * time.Second),
+			expect:       true,
+		},
+		{
+			name:         "expired",
+			record:       leaseRecord{Holder: "other", LeaseDuration: 15},
+			observedTime: now.Add(-20 * time.Second),
+		},
+		{
+			// The renew time is by the clock of holder, which is ignored.
+			name: "the clock of holder is behind",
+			record: leaseRecord{
+				Holder: "other", RenewTime: now.Add(-time.Hour), LeaseDuration: 15,
+			},
+			observedTime: now,
+			expect:       true,
+		},
+		{
+			name: "the clock of holder is ahead",
+			record: leaseRecord{
+				Holder: "other", RenewTime: now.Add(time.Hour), LeaseDuration: 15,
+			},
+			observedTime: now.Add(-20 * time.Second),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if v := c.record.isHeldByOthers("me", c.observedTime, now); v != c.expect {
+				t.Errorf("expect %t, got %t", c.expect, v)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/opensourceways/robot-gitee-repo-watcher/models"
 )
 
@@ -27,8 +28,29 @@ func (r *localState) clear(isExpectedRepo func(string) bool) {
 	}
 }
 
-func (bot *robot) loadALLRepos(org string) (*localState, error) {
-	items, err := bot.cli.GetRepos(org)
+// results returns the results of the repos which have been handled.
+func (r *localState) results() map[string]models.RepoResult {
+	m := make(map[string]models.RepoResult)
+	for k, v := range r.repos {
+		if res := v.Result(); !res.LastAttempt.IsZero() {
+			m[k] = res
+		}
+	}
+
+	return m
+}
+
+// restoreResults restores the results of the repos which have not been handled.
+func (r *localState) restoreResults(m map[string]models.RepoResult) {
+	for k, v := range m {
+		if lr, ok := r.repos[k]; ok {
+			lr.RestoreResult(v)
+		}
+	}
+}
+
+func (bot *robot) loadALLRepos(ctx context.Context, org string) (*localState, error) {
+	items, err := bot.cli.GetRepos(ctx, org)
 	if err != nil {
 		return nil, err
 	}
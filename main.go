@@ -10,20 +10,27 @@ import (
 	"syscall"
 
 	"github.com/opensourceways/community-robot-lib/config"
-	"github.com/opensourceways/community-robot-lib/giteeclient"
 	"github.com/opensourceways/community-robot-lib/logrusutil"
 	liboptions "github.com/opensourceways/community-robot-lib/options"
 	"github.com/opensourceways/community-robot-lib/secret"
-	"github.com/panjf2000/ants/v2"
 	"github.com/sirupsen/logrus"
 )
 
 type options struct {
-	gitee      liboptions.GiteeOptions
-	configFile string
+	gitee        liboptions.GiteeOptions
+	configFile   string
+	previewPR    int
+	reportFormat string
+	reportOutput string
 }
 
 func (o *options) Validate() error {
+	switch o.reportFormat {
+	case "", reportFormatJSON, reportFormatCSV, reportFormatHTML:
+	default:
+		return fmt.Errorf("unknown report format:%s", o.reportFormat)
+	}
+
 	return o.gitee.Validate()
 }
 
@@ -33,6 +40,15 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	o.gitee.AddFlags(fs)
 
 	fs.StringVar(&o.configFile, "config-file", "", "Path to config file.")
+	fs.IntVar(
+		&o.previewPR, "preview-pr", 0,
+		"Number of pull request of community repo to preview. It will exit after commenting the planned actions on it.",
+	)
+	fs.StringVar(
+		&o.reportFormat, "report-format", "",
+		"Format of the report of repos, it can be json, csv or html. It will exit after generating the report.",
+	)
+	fs.StringVar(&o.reportOutput, "report-output", "", "Path to the report file. Default to stdout.")
 
 	fs.Parse(args)
 	return o
@@ -56,27 +72,27 @@ func main() {
 		logrus.WithError(err).Fatal("Error generating client.")
 	}
 
-	pool, err := newPool(cfg.ConcurrentSize, logWapper{})
-	if err != nil {
-		logrus.WithError(err).Fatal("Error starting goroutine pool.")
-	}
-	defer pool.Release()
+	p := newRobot(c, &cfg)
 
-	p := newRobot(c, pool, &cfg)
+	if o.previewPR > 0 {
+		log := logrus.NewEntry(logrus.StandardLogger())
+		if err := p.preview(int32(o.previewPR), log); err != nil {
+			log.WithError(err).Fatal("Error previewing the pull request.")
+		}
 
-	run(p)
-}
+		return
+	}
 
-func newPool(size int, log ants.Logger) (*ants.Pool, error) {
-	return ants.NewPool(size, ants.WithOptions(ants.Options{
-		Logger: log,
-	}))
-}
+	if o.reportFormat != "" {
+		log := logrus.NewEntry(logrus.StandardLogger())
+		if err := p.genReport(o.reportFormat, o.reportOutput, log); err != nil {
+			log.WithError(err).Fatal("Error generating the report.")
+		}
 
-type logWapper struct{}
+		return
+	}
 
-func (l logWapper) Printf(format string, args ...interface{}) {
-	logrus.Infof(format, args...)
+	run(p)
 }
 
 func getConfig(configFile string) (botConfig, error) {
@@ -108,7 +124,7 @@ func genClient(tokenPath string) (iClient, error) {
 	secretAgent.Stop()
 
 	t := secretAgent.GetTokenGenerator(tokenPath)
-	return giteeclient.NewClient(t), nil
+	return newGiteeClient(t), nil
 }
 
 func run(bot *robot) {
@@ -1,8 +1,11 @@
 package models
 
-import "github.com/opensourceways/robot-gitee-repo-watcher/community"
+import (
+	"sync"
+	"time"
 
-var empty = struct{}{}
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
 
 type RepoProperty struct {
 	Private    bool
@@ -15,30 +18,85 @@ type RepoState struct {
 	Members   []string
 	Owner     string
 	Property  RepoProperty
+
+	// OwnersFileHash is the git blob sha of owners file content which was committed last time.
+	OwnersFileHash string
+}
+
+// StepError is the error of a step when handling the repo, such as creating branch.
+type StepError struct {
+	Step  string `json:"step"`
+	Error string `json:"error"`
+}
+
+// RepoResult is the result of handling the repo.
+type RepoResult struct {
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+
+	// Errors are the errors of steps at the last attempt.
+	Errors []StepError `json:"errors,omitempty"`
 }
 
 type Repo struct {
-	name  string
-	state RepoState
-	start chan struct{}
+	name   string
+	state  RepoState
+	result RepoResult
+	lock   sync.RWMutex
+
+	// updating serializes the updates of repo.
+	updating sync.Mutex
 }
 
 func NewRepo(repo string, state RepoState) *Repo {
 	return &Repo{
 		name:  repo,
 		state: state,
-		start: make(chan struct{}, 1),
 	}
 }
 
-func (r *Repo) Update(f func(RepoState) RepoState) {
-	select {
-	case r.start <- empty:
-		defer func() {
-			<-r.start
-		}()
+// State returns the state of repo. It may be out of date if the repo is being updated.
+func (r *Repo) State() RepoState {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.state
+}
+
+// Result returns the result of handling the repo.
+func (r *Repo) Result() RepoResult {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.result
+}
+
+// RestoreResult sets the result which is persisted before if the repo has not been handled.
+func (r *Repo) RestoreResult(v RepoResult) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.result.LastAttempt.IsZero() {
+		r.result = v
+	}
+}
+
+// Update handles the repo by f and records the result. It waits
+// if the repo is being handled.
+func (r *Repo) Update(f func(RepoState) (RepoState, []StepError)) {
+	r.updating.Lock()
+	defer r.updating.Unlock()
+
+	t := time.Now()
+	s, errs := f(r.State())
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
-		r.state = f(r.state)
-	default:
+	r.state = s
+	r.result.LastAttempt = t
+	r.result.Errors = errs
+	if len(errs) == 0 {
+		r.result.LastSuccess = t
 	}
 }
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	eventRepoCreated     = "repo_created"
+	eventRepoRenamed     = "repo_renamed"
+	eventRepoTransferred = "repo_transferred"
+	eventRepoRemoved     = "repo_removed"
+	eventBranchCreated   = "branch_created"
+	eventBranchUpdated   = "branch_updated"
+	eventMemberAdded     = "member_added"
+	eventMemberRemoved   = "member_removed"
+	eventStatusChanged   = "status_changed"
+)
+
+const defaultNotificationTemplate = `The repos of {{if .Sig}}sig:{{.Sig}}{{else}}no sig{{end}} in {{.Org}} have been changed.
+{{range .Events}}
+- {{.Time.Format "2006-01-02 15:04:05"}} {{.Kind}}: {{.Repo}}{{if .Target}} {{.Target}}{{end}}{{end}}
+{{if .Members}}
+cc {{range .Members}}@{{.}} {{end}}{{end}}
+`
+
+// changeEvent is a change made to the repo.
+type changeEvent struct {
+	Kind string `json:"kind"`
+	Repo string `json:"repo"`
+	Sig  string `json:"sig,omitempty"`
+
+	// Target is the member, the branch or the former repo which is changed.
+	Target string    `json:"target,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// changeEvents are the changes which will be sent at the end of each check.
+type changeEvents struct {
+	lock  sync.Mutex
+	items []changeEvent
+}
+
+func (c *changeEvents) add(kind string, expectRepo *expectRepoInfo, target string) {
+	c.addEvent(changeEvent{
+		Kind:   kind,
+		Repo:   expectRepo.getNewRepoName(),
+		Sig:    expectRepo.sig,
+		Target: target,
+	})
+}
+
+func (c *changeEvents) addEvent(e changeEvent) {
+	e.Time = time.Now()
+
+	c.lock.Lock()
+	c.items = append(c.items, e)
+	c.lock.Unlock()
+}
+
+func (c *changeEvents) take() []changeEvent {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	r := c.items
+	c.items = nil
+
+	return r
+}
+
+// notificationData is the data which can be referenced by the template of notification.
+type notificationData struct {
+	Org    string        `json:"org"`
+	Sig    string        `json:"sig,omitempty"`
+	Events []changeEvent `json:"events"`
+
+	// Members are the members who are added or removed.
+	Members []string `json:"members,omitempty"`
+}
+
+type notifier interface {
+	notify(ctx context.Context, subject, content string, data *notificationData) error
+}
+
+// sendNotifications groups the changes by sig and sends them through the channels of each sig.
+func (bot *robot) sendNotifications(ctx context.Context, org string, events []changeEvent, log *logrus.Entry) {
+	cfg := &bot.cfg.Notification
+	if !cfg.Enable || len(events) == 0 {
+		return
+	}
+
+	groups := make(map[string][]changeEvent)
+	for i := range events {
+		item := &events[i]
+		groups[item.Sig] = append(groups[item.Sig], *item)
+	}
+
+	sigs := make([]string, 0, len(groups))
+	for k := range groups {
+		sigs = append(sigs, k)
+	}
+	sort.Strings(sigs)
+
+	for _, sig := range sigs {
+		data := newNotificationData(org, sig, groups[sig])
+
+		content, err := execTemplate(cfg.contentTemplate, &data)
+		if err != nil {
+			log.Errorf("generate the notification of sig:%s, err:%s", sig, err.Error())
+			continue
+		}
+
+		subject := fmt.Sprintf("[%s] the repos of %s are changed", botName, org)
+		if sig != "" {
+			subject = fmt.Sprintf("[%s] the repos of sig:%s in %s are changed", botName, sig, org)
+		}
+
+		for i := range cfg.Channels {
+			ch := &cfg.Channels[i]
+			if !ch.isRouted(sig) {
+				continue
+			}
+
+			if err := bot.newNotifier(ch, sig).notify(ctx, subject, content, &data); err != nil {
+				log.Errorf("send notification through channel:%s, err:%s", ch.Name, err.Error())
+			}
+		}
+	}
+}
+
+func newNotificationData(org, sig string, events []changeEvent) notificationData {
+	members := sets.NewString()
+	for i := range events {
+		if k := events[i].Kind; k == eventMemberAdded || k == eventMemberRemoved {
+			members.Insert(events[i].Target)
+		}
+	}
+
+	return notificationData{
+		Org:     org,
+		Sig:     sig,
+		Events:  events,
+		Members: members.List(),
+	}
+}
+
+func (bot *robot) newNotifier(ch *notifyChannel, sig string) notifier {
+	switch ch.Type {
+	case notifyChannelEmail:
+		return emailNotifier{cfg: ch.Email, sig: sig}
+
+	case notifyChannelIssueComment:
+		return issueCommentNotifier{cli: bot.cli, cfg: ch.Issue}
+
+	default:
+		return webhookNotifier{cfg: ch.Webhook}
+	}
+}
+
+type emailNotifier struct {
+	cfg *emailChannel
+	sig string
+}
+
+func (n emailNotifier) notify(ctx context.Context, subject, content string, data *notificationData) error {
+	cfg := n.cfg
+
+	to := append([]string{}, cfg.To...)
+	to = append(to, cfg.SigTo[n.sig]...)
+	if len(to) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		cfg.From, strings.Join(to, ", "), subject, content,
+	)
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.password, cfg.Host)
+	}
+
+	return sendMail(ctx, cfg.Host, cfg.Port, auth, cfg.From, to, []byte(msg))
+}
+
+// emailTimeout is the max duration of sending an email.
+const emailTimeout = 30 * time.Second
+
+// sendMail does as smtp.SendMail, but it gives up when the ctx is done or it
+// can't finish within the emailTimeout, so that a hung server can't block the watcher.
+func sendMail(
+	ctx context.Context, host string, port int, auth smtp.Auth, from string, to []string, msg []byte,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", host, port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if v, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(v); err != nil {
+			return err
+		}
+	}
+
+	// Unblock the reading or writing at once when the ctx is cancelled.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+type issueCommentNotifier struct {
+	cli iClient
+	cfg *issueChannel
+}
+
+func (n issueCommentNotifier) notify(ctx context.Context, subject, content string, data *notificationData) error {
+	return n.cli.CreateIssueComment(ctx, n.cfg.Org, n.cfg.Repo, n.cfg.Number, content)
+}
+
+type webhookNotifier struct {
+	cfg *webhookChannel
+}
+
+func (n webhookNotifier) notify(ctx context.Context, subject, content string, data *notificationData) error {
+	v, err := json.Marshal(struct {
+		Subject string `json:"subject"`
+		Content string `json:"content"`
+
+		*notificationData
+	}{subject, content, data})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.URL, bytes.NewReader(v))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	hc := http.Client{Timeout: 30 * time.Second}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post to %s failed, status code:%d", n.cfg.URL, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func parseNotificationTemplate(p string) (*template.Template, error) {
+	if p == "" {
+		return parseTemplate("notification", defaultNotificationTemplate)
+	}
+
+	return parseTemplateFile(p)
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+const (
+	feedbackWaiting = "waiting"
+	feedbackDone    = "done"
+	feedbackFailed  = "failed, it will be retried"
+	feedbackSkipped = "skipped, the repo is frozen"
+	feedbackDropped = "dropped, the repo is not in the repo file any more"
+)
+
+// the message of merge commit on gitee is like: Merge pull request !123 from xxx
+var mergedPRRe = regexp.MustCompile(`Merge pull request !(\d+)`)
+
+type repoFeedback struct {
+	status  string
+	changes []string
+}
+
+// prFeedback is the result of handling the repos changed by a pull request of community repo.
+type prFeedback struct {
+	number    int32
+	commentID int32
+	repos     map[string]*repoFeedback
+	dirty     bool
+}
+
+func (p *prFeedback) isComplete() bool {
+	for _, v := range p.repos {
+		if v.status != feedbackDone && v.status != feedbackSkipped && v.status != feedbackDropped {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *prFeedback) genComment() string {
+	names := make([]string, 0, len(p.repos))
+	for k := range p.repos {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	s := []string{
+		fmt.Sprintf("The repos changed by this pull request are handled by the %s.", botName),
+		"",
+		"| repo | status | changes |",
+		"| --- | --- | --- |",
+	}
+	for _, k := range names {
+		v := p.repos[k]
+		s = append(s, fmt.Sprintf("| %s | %s | %s |", k, v.status, strings.Join(v.changes, ", ")))
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// prFeedbacks tracks the pull requests which are waiting for the results.
+// A repo only belongs to the latest pull request which changes it.
+type prFeedbacks struct {
+	lock   sync.Mutex
+	prs    map[int32]*prFeedback
+	byRepo map[string]int32
+}
+
+func (f *prFeedbacks) track(number int32, repos []string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.prs == nil {
+		f.prs = make(map[int32]*prFeedback)
+		f.byRepo = make(map[string]int32)
+	}
+
+	pr, ok := f.prs[number]
+	if !ok {
+		pr = &prFeedback{number: number, repos: make(map[string]*repoFeedback)}
+		f.prs[number] = pr
+	}
+
+	for _, repo := range repos {
+		if n, ok := f.byRepo[repo]; ok && n != number {
+			if old := f.prs[n]; old != nil {
+				delete(old.repos, repo)
+				old.dirty = true
+			}
+		}
+
+		f.byRepo[repo] = number
+		pr.repos[repo] = &repoFeedback{status: feedbackWaiting}
+	}
+
+	pr.dirty = true
+}
+
+func (f *prFeedbacks) report(repo string, done bool) {
+	status := feedbackFailed
+	if done {
+		status = feedbackDone
+	}
+
+	f.setStatus(repo, status)
+}
+
+// skip records that the repo is not handled because it is frozen.
+func (f *prFeedbacks) skip(repo string) {
+	f.setStatus(repo, feedbackSkipped)
+}
+
+// drop resolves the repos which are waiting or failed but no longer expected,
+// because they will never be handled again.
+func (f *prFeedbacks) drop(isExpectedRepo func(string) bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for repo := range f.byRepo {
+		if isExpectedRepo(repo) {
+			continue
+		}
+
+		pr := f.getPR(repo)
+		if pr == nil {
+			continue
+		}
+
+		if v := pr.repos[repo]; v.status == feedbackWaiting || v.status == feedbackFailed {
+			v.status = feedbackDropped
+			pr.dirty = true
+		}
+	}
+}
+
+func (f *prFeedbacks) setStatus(repo, status string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	pr := f.getPR(repo)
+	if pr == nil {
+		return
+	}
+
+	if v := pr.repos[repo]; v.status != status {
+		v.status = status
+		pr.dirty = true
+	}
+}
+
+func (f *prFeedbacks) addEvents(events []changeEvent) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i := range events {
+		item := &events[i]
+
+		pr := f.getPR(item.Repo)
+		if pr == nil {
+			continue
+		}
+
+		c := item.Kind
+		if item.Target != "" {
+			c += ":" + item.Target
+		}
+
+		v := pr.repos[item.Repo]
+		v.changes = append(v.changes, c)
+		pr.dirty = true
+	}
+}
+
+func (f *prFeedbacks) getPR(repo string) *prFeedback {
+	n, ok := f.byRepo[repo]
+	if !ok {
+		return nil
+	}
+
+	return f.prs[n]
+}
+
+type prComment struct {
+	number    int32
+	commentID int32
+	content   string
+	complete  bool
+}
+
+// takeDirty returns the comments of pull requests which need to be updated.
+func (f *prFeedbacks) takeDirty() []prComment {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	r := []prComment{}
+	for _, v := range f.prs {
+		if v.dirty {
+			r = append(r, prComment{
+				number:    v.number,
+				commentID: v.commentID,
+				content:   v.genComment(),
+				complete:  v.isComplete(),
+			})
+
+			v.dirty = false
+		}
+	}
+
+	return r
+}
+
+// done records the comment and stops tracking the pull request if it is complete.
+func (f *prFeedbacks) done(number, commentID int32, complete bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	pr, ok := f.prs[number]
+	if !ok {
+		return
+	}
+
+	pr.commentID = commentID
+
+	if !complete || pr.dirty {
+		return
+	}
+
+	for k := range pr.repos {
+		delete(f.byRepo, k)
+	}
+	delete(f.prs, number)
+}
+
+func (f *prFeedbacks) markDirty(number int32) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if pr, ok := f.prs[number]; ok {
+		pr.dirty = true
+	}
+}
+
+// maxTrackedCommits is the max number of commits to walk when tracking the changed repos.
+const maxTrackedCommits = 100
+
+// trackChangedRepos finds the merged pull requests which change the repos. It walks
+// the commits since last check, and each repo belongs to the latest pull request
+// which changes it. The repos changed by the commits which are not made by
+// pull requests are not tracked.
+func (bot *robot) trackChangedRepos(ctx context.Context, w *repoBranch, c *repoChanges, log *logrus.Entry) {
+	if !bot.cfg.CommentOnCommunityPR || c.isEmpty() {
+		return
+	}
+
+	if c.from == "" || c.to == "" {
+		log.Info("the commits of community repo are unknown, skip tracking the changed repos")
+		return
+	}
+
+	commits, err := bot.listCommitsSince(ctx, w, c.from, c.to)
+	if err != nil {
+		log.Errorf("list the commits of community repo, err:%s", err.Error())
+		return
+	}
+
+	changed := sets.NewString(c.repos...)
+	lastRepos := c.lastRepos
+	prs := make(map[string]int32)
+
+	for i := range commits {
+		item := &commits[i]
+
+		repos := []string{}
+		for _, f := range item.Files {
+			if f != c.repoFile {
+				repos = append(repos, c.owners[f]...)
+				continue
+			}
+
+			v, err := bot.loadReposAt(ctx, w, f, item.SHA)
+			if err != nil {
+				log.Errorf("load file:%s at commit:%s, err:%s", f, item.SHA, err.Error())
+				continue
+			}
+
+			for _, k := range diffRepos(lastRepos, v) {
+				if changed.Has(k) {
+					repos = append(repos, k)
+				}
+			}
+			lastRepos = v
+		}
+
+		n := parseMergedPR(item.Message)
+		for _, k := range repos {
+			if n > 0 {
+				prs[k] = n
+			} else {
+				delete(prs, k)
+			}
+		}
+	}
+
+	byPR := make(map[int32][]string)
+	for k, n := range prs {
+		byPR[n] = append(byPR[n], k)
+	}
+
+	for n, repos := range byPR {
+		bot.feedback.track(n, repos)
+	}
+}
+
+// listCommitsSince returns the commits after the commit of from until the one of to
+// in the order they were made. It only walks the first parents, which are the merge
+// commits of pull requests generally.
+func (bot *robot) listCommitsSince(ctx context.Context, w *repoBranch, from, to string) ([]commitInfo, error) {
+	r := []commitInfo{}
+
+	for sha := to; sha != from; {
+		if len(r) == maxTrackedCommits {
+			return nil, fmt.Errorf("there are more than %d commits since %s", maxTrackedCommits, from)
+		}
+
+		v, err := bot.cli.GetCommit(ctx, w.Org, w.Repo, sha)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(v.Parents) == 0 {
+			return nil, fmt.Errorf("the commit:%s is not found", from)
+		}
+
+		r = append(r, v)
+		sha = v.Parents[0]
+	}
+
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+
+	return r, nil
+}
+
+func (bot *robot) loadReposAt(
+	ctx context.Context, w *repoBranch, file, sha string,
+) (map[string]*community.Repository, error) {
+	v := new(community.Repos)
+	if err := bot.loadFileAt(ctx, w, file, sha, v); err != nil {
+		return nil, err
+	}
+
+	return v.GetRepos(), nil
+}
+
+// loadFileAt loads the file at the commit of repo. The object is validated,
+// so that the data derived from the file is available.
+func (bot *robot) loadFileAt(ctx context.Context, w *repoBranch, file, sha string, v watchingFileObject) error {
+	c, err := bot.cli.GetPathContent(ctx, w.Org, w.Repo, file, sha)
+	if err != nil {
+		return err
+	}
+
+	if err := decodeYamlFile(c.Content, v); err != nil {
+		return err
+	}
+
+	return v.Validate()
+}
+
+// parseMergedPR returns the number of pull request which makes the merge commit.
+// It returns 0 if the commit is not made by a pull request.
+func parseMergedPR(msg string) int32 {
+	m := mergedPRRe.FindStringSubmatch(msg)
+	if len(m) != 2 {
+		return 0
+	}
+
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+
+	return int32(n)
+}
+
+// submitPRFeedback comments the results on the pull requests and updates the comments when the results change.
+func (bot *robot) submitPRFeedback(ctx context.Context, w *repoBranch, events []changeEvent, log *logrus.Entry) {
+	if !bot.cfg.CommentOnCommunityPR {
+		return
+	}
+
+	bot.feedback.addEvents(events)
+
+	for _, pr := range bot.feedback.takeDirty() {
+		id := pr.commentID
+		var err error
+		if id == 0 {
+			id, err = bot.cli.AddPRComment(ctx, w.Org, w.Repo, pr.number, pr.content)
+		} else {
+			err = bot.cli.EditPRComment(ctx, w.Org, w.Repo, id, pr.content)
+		}
+
+		if err != nil {
+			log.Errorf("comment on the pull request:%d, err:%s", pr.number, err.Error())
+
+			bot.feedback.markDirty(pr.number)
+			continue
+		}
+
+		bot.feedback.done(pr.number, id, pr.complete)
+	}
+}
+
+// checkRepoConverged returns the reason why the repo is not as expected.
+// It returns empty string if the repo has been handled as expected.
+func checkRepoConverged(s *models.RepoState, expectRepo *expectRepoInfo) string {
+	if !s.Available {
+		return "the repo is not available"
+	}
+
+	d := diffRepo(expectRepo, s)
+
+	r := []string{}
+
+	if v := d.branches.missing; len(v) > 0 {
+		r = append(r, "missing branches: "+strings.Join(branchNames(v), ", "))
+	}
+
+	if v := d.members.missing; len(v) > 0 {
+		r = append(r, "missing members: "+strings.Join(v, ", "))
+	}
+
+	return strings.Join(r, "; ")
+}
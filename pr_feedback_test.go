@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+func TestCheckRepoConverged(t *testing.T) {
+	repo := community.Repository{
+		Type: "public",
+		Branches: []community.RepoBranch{
+			{Name: community.BranchMaster},
+			{Name: "dev"},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		state  models.RepoState
+		expect string
+	}{
+		{
+			name:   "not available",
+			expect: "the repo is not available",
+		},
+		{
+			name: "converged",
+			state: models.RepoState{
+				Available: true,
+				Branches:  []community.RepoBranch{{Name: community.BranchMaster}, {Name: "dev"}},
+				Members:   []string{"alice", "bob"},
+			},
+		},
+		{
+			// The unexpected members and properties are not checked.
+			name: "converged with the unexpected members",
+			state: models.RepoState{
+				Available: true,
+				Branches:  []community.RepoBranch{{Name: community.BranchMaster}, {Name: "dev"}},
+				Members:   []string{"alice", "bob", "carol"},
+				Property:  models.RepoProperty{Private: true},
+			},
+		},
+		{
+			name: "missing branches and members",
+			state: models.RepoState{
+				Available: true,
+				Branches:  []community.RepoBranch{{Name: community.BranchMaster}},
+				Members:   []string{"alice"},
+			},
+			expect: "missing branches: dev; missing members: bob",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newExpectRepoInfo(
+				"org", &repo, "sig", community.EffectiveOwners{Maintainers: []string{"alice", "bob"}}, nil,
+			)
+
+			if v := checkRepoConverged(&c.state, e); v != c.expect {
+				t.Errorf("expect %q, got %q", c.expect, v)
+			}
+		})
+	}
+}
+
+func TestPRFeedbacksDrop(t *testing.T) {
+	f := prFeedbacks{}
+	f.track(1, []string{"a", "b", "c", "d"})
+
+	f.report("a", true)
+	f.report("b", false)
+	f.takeDirty()
+
+	f.drop(func(repo string) bool { return repo == "d" })
+
+	expect := map[string]string{
+		"a": feedbackDone,
+		"b": feedbackDropped,
+		"c": feedbackDropped,
+		"d": feedbackWaiting,
+	}
+	for k, v := range expect {
+		if s := f.prs[1].repos[k].status; s != v {
+			t.Errorf("repo:%s, expect %q, got %q", k, v, s)
+		}
+	}
+
+	r := f.takeDirty()
+	if len(r) != 1 || r[0].complete {
+		t.Fatalf("expect an incomplete comment to update, got %+v", r)
+	}
+
+	// It is complete after the repo in the repo file is done.
+	f.report("d", true)
+
+	r = f.takeDirty()
+	if len(r) != 1 || !r[0].complete {
+		t.Fatalf("expect a complete comment to update, got %+v", r)
+	}
+
+	f.done(1, 100, true)
+
+	if len(f.prs) != 0 || len(f.byRepo) != 0 {
+		t.Errorf("expect the pull request not to be tracked")
+	}
+}
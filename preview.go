@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
+
+// previewMarker marks the comment of preview, so that it is updated instead
+// of adding a new one when previewing the same pull request again.
+const previewMarker = "<!-- repo-watcher-preview -->"
+
+// preview comments the actions which will be taken after the pull request
+// of community repo is merged. It does not change anything of the repos.
+func (bot *robot) preview(number int32, log *logrus.Entry) error {
+	ctx := context.Background()
+	w := &bot.cfg.WatchingFiles
+
+	if err := bot.statuses.load(bot.cfg.StatusHistoryFile); err != nil {
+		return err
+	}
+
+	pr, err := bot.cli.GetPullRequest(ctx, w.Org, w.Repo, number)
+	if err != nil {
+		return err
+	}
+
+	head := repoBranch{Org: w.Org, Repo: w.Repo, Branch: pr.Head.Sha}
+	if a := strings.Split(pr.Head.Repo.FullName, "/"); len(a) == 2 {
+		head.Org, head.Repo = a[0], a[1]
+	}
+
+	base := w.repoBranch
+	base.Branch = pr.Base.Ref
+
+	org, baseRepos, err := bot.loadExpectRepos(ctx, base, log)
+	if err != nil {
+		return fmt.Errorf("load the repos of base, err:%s", err.Error())
+	}
+
+	_, headRepos, err := bot.loadExpectRepos(ctx, head, log)
+	if err != nil {
+		return fmt.Errorf("load the repos of head, err:%s", err.Error())
+	}
+
+	local, err := bot.loadALLRepos(ctx, org)
+	if err != nil {
+		return err
+	}
+
+	comment := bot.genPreview(ctx, baseRepos, headRepos, local) + "\n\n" + previewMarker
+
+	comments, err := bot.cli.ListPRComments(ctx, w.Org, w.Repo, number)
+	if err != nil {
+		return fmt.Errorf("list the comments, err:%s", err.Error())
+	}
+
+	for i := range comments {
+		if item := &comments[i]; strings.Contains(item.Body, previewMarker) {
+			return bot.cli.EditPRComment(ctx, w.Org, w.Repo, item.ID, comment)
+		}
+	}
+
+	_, err = bot.cli.AddPRComment(ctx, w.Org, w.Repo, number, comment)
+
+	return err
+}
+
+// loadExpectRepos loads the repos and their owners from the branch or commit of community repo.
+func (bot *robot) loadExpectRepos(
+	ctx context.Context, b repoBranch, log *logrus.Entry,
+) (string, map[string]expectRepoInfo, error) {
+	r := make(map[string]expectRepoInfo)
+
+	org, err := bot.checkExpectation(ctx, b, log, func(expectRepo *expectRepoInfo) {
+		r[expectRepo.getNewRepoName()] = *expectRepo
+	})
+
+	return org, r, err
+}
+
+// checkExpectation loads the expectation from the branch or commit of community repo once
+// and calls f for each repo without changing anything.
+func (bot *robot) checkExpectation(
+	ctx context.Context, b repoBranch, log *logrus.Entry, f func(*expectRepoInfo),
+) (string, error) {
+	w := &bot.cfg.WatchingFiles
+	expect := &expectState{
+		w:         b,
+		log:       log,
+		cli:       bot.cli,
+		sigOwners: make(map[string]*expectSigOwners),
+		users:     newUserResolver(bot.cli, bot.cfg.userCacheTTL()),
+	}
+
+	org, err := expect.init(
+		ctx, w.RepoFilePath, w.SigFilePath, w.SigDir, w.IdentityFilePath, w.PeerRepoFilePaths,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	expect.check(
+		ctx,
+		org,
+		func() bool { return false },
+		func(func(string) bool) {},
+		func(repo *community.Repository, sig string, owners community.EffectiveOwners, log *logrus.Entry) {
+			if repo != nil {
+				v := newExpectRepoInfo(org, repo, sig, owners, expect.getRepos())
+				v.frozen = expect.getFrozenReason(repo, sig)
+
+				f(v)
+			}
+		},
+		nil,
+	)
+
+	return org, nil
+}
+
+func (bot *robot) genPreview(ctx context.Context, base, head map[string]expectRepoInfo, local *localState) string {
+	names := sets.NewString()
+	for k := range base {
+		names.Insert(k)
+	}
+	for k := range head {
+		names.Insert(k)
+	}
+
+	// The repos which are renamed or transferred to others are not removed.
+	moved := sets.NewString()
+	for _, h := range head {
+		if v := h.expectRepoState.RenameFrom; v != "" {
+			moved.Insert(v)
+		}
+		if org, v := h.expectRepoState.GetTransferFrom(); org == h.org && v != "" {
+			moved.Insert(v)
+		}
+	}
+
+	s := []string{}
+	for _, k := range names.List() {
+		h, ok := head[k]
+		if !ok {
+			if moved.Has(k) {
+				continue
+			}
+
+			s = append(s, fmt.Sprintf("- **%s**: it is removed from the repo file, but the repo will be kept", k))
+			continue
+		}
+
+		if b, ok := base[k]; ok && isSameExpectRepo(&b, &h) {
+			continue
+		}
+
+		actions := bot.planRepo(ctx, &h, local)
+		if len(actions) == 0 {
+			continue
+		}
+
+		s = append(s, fmt.Sprintf("- **%s**", k))
+		for _, item := range actions {
+			s = append(s, "  - "+item)
+		}
+	}
+
+	if len(s) == 0 {
+		return fmt.Sprintf("The %s will do nothing after this pull request is merged.", botName)
+	}
+
+	return fmt.Sprintf(
+		"The %s will do as below after this pull request is merged.\n\n%s",
+		botName, strings.Join(s, "\n"),
+	)
+}
+
+// planRepo returns the actions which will be taken for the repo.
+func (bot *robot) planRepo(ctx context.Context, expectRepo *expectRepoInfo, local *localState) []string {
+	org := expectRepo.org
+	repo := expectRepo.expectRepoState
+	name := expectRepo.getNewRepoName()
+
+	if expectRepo.frozen != "" {
+		return []string{fmt.Sprintf("nothing will be done, because %s", expectRepo.frozen)}
+	}
+
+	r := []string{}
+
+	lr, ok := local.repos[name]
+	if !ok || !lr.State().Available {
+		switch {
+		case repo.RenameFrom != "" && repo.RenameFrom != name:
+			r = append(r, "rename from "+repo.RenameFrom)
+		case repo.TransferFrom != "":
+			r = append(r, "transfer from "+repo.TransferFrom)
+		case repo.ForkFrom != "":
+			r = append(r, "create by forking from "+repo.ForkFrom)
+		case repo.ImportURL != "":
+			r = append(r, "create by importing from "+repo.ImportURL)
+		default:
+			r = append(r, "create")
+		}
+
+		if v := expectRepo.expectOwners; len(v) > 0 {
+			r = append(r, "add members: "+strings.Join(sortedStrings(v), ", "))
+		}
+
+		bs := []string{}
+		ps := []string{}
+		for _, item := range repo.Branches {
+			if item.Name != community.BranchMaster {
+				bs = append(bs, item.Name)
+			}
+			if item.Type == community.BranchProtected {
+				ps = append(ps, item.Name)
+			}
+		}
+		if len(bs) > 0 {
+			r = append(r, "create branches: "+strings.Join(bs, ", "))
+		}
+		if len(ps) > 0 {
+			r = append(r, "protect branches: "+strings.Join(ps, ", "))
+		}
+
+		return r
+	}
+
+	state := lr.State()
+
+	branches, err := bot.listAllBranchOfRepo(ctx, org, name)
+	if err != nil {
+		return append(r, fmt.Sprintf("can't check the branches, err:%s", err.Error()))
+	}
+	state.Branches = branches
+
+	d := diffRepo(expectRepo, &state)
+
+	if d.propertyChanged {
+		r = append(r, fmt.Sprintf(
+			"update property: private=%t, commentable=%t", repo.IsPrivate(), repo.Commentable,
+		))
+	}
+
+	if v := repo.GetStatus(); statusPolicy(v) != statusPolicy(bot.statuses.get(name).Status) {
+		r = append(r, "apply the policy of status: "+v)
+	}
+
+	if v := d.members.missing; len(v) > 0 {
+		r = append(r, "add members: "+strings.Join(v, ", "))
+	}
+
+	if removed := bot.membersToRemove(expectRepo, d.members.unexpected); len(removed) > 0 {
+		if bot.cfg.UnexpectedMembers.ReportOnly {
+			r = append(r, "report unexpected members: "+strings.Join(removed, ", "))
+		} else {
+			r = append(r, "remove members: "+strings.Join(removed, ", "))
+		}
+	}
+
+	for _, item := range d.branches.updated {
+		if item.expect.Type == community.BranchProtected {
+			r = append(r, "protect branch: "+item.expect.Name)
+		} else {
+			r = append(r, "unprotect branch: "+item.expect.Name)
+		}
+	}
+
+	for _, item := range d.branches.missing {
+		r = append(r, fmt.Sprintf("create branch: %s from %s", item.Name, getCreateFrom(&item)))
+	}
+
+	return r
+}
+
+func isSameExpectRepo(a, b *expectRepoInfo) bool {
+	return a.sig == b.sig &&
+		reflect.DeepEqual(a.expectRepoState, b.expectRepoState) &&
+		sets.NewString(a.expectOwners...).Equal(sets.NewString(b.expectOwners...)) &&
+		sets.NewString(a.committers...).Equal(sets.NewString(b.committers...))
+}
+
+func sortedStrings(v []string) []string {
+	r := append([]string{}, v...)
+	sort.Strings(r)
+
+	return r
+}
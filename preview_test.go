@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+// fakeBranchClient returns the branches of each repo.
+type fakeBranchClient struct {
+	iClient
+
+	branches map[string][]sdk.Branch
+}
+
+func (c *fakeBranchClient) GetRepoAllBranch(ctx context.Context, org, repo string) ([]sdk.Branch, error) {
+	return c.branches[repo], nil
+}
+
+func TestGenPreview(t *testing.T) {
+	newRepo := func(repo community.Repository, owners ...string) expectRepoInfo {
+		if repo.Type == "" {
+			repo.Type = "public"
+		}
+
+		return *newExpectRepoInfo(
+			"org", &repo, "sig", community.EffectiveOwners{Maintainers: owners}, nil,
+		)
+	}
+
+	local := &localState{repos: map[string]*models.Repo{
+		"kept": models.NewRepo("kept", models.RepoState{
+			Available: true,
+			Members:   []string{"alice", "carol"},
+		}),
+	}}
+
+	cli := &fakeBranchClient{branches: map[string][]sdk.Branch{
+		"kept": {{Name: community.BranchMaster}},
+	}}
+
+	cases := []struct {
+		name   string
+		base   map[string]expectRepoInfo
+		head   map[string]expectRepoInfo
+		expect []string
+	}{
+		{
+			name: "nothing is changed",
+			base: map[string]expectRepoInfo{"kept": newRepo(community.Repository{Name: "kept"})},
+			head: map[string]expectRepoInfo{"kept": newRepo(community.Repository{Name: "kept"})},
+		},
+		{
+			name: "removed",
+			base: map[string]expectRepoInfo{"kept": newRepo(community.Repository{Name: "kept"})},
+			head: map[string]expectRepoInfo{},
+			expect: []string{
+				"- **kept**: it is removed from the repo file, but the repo will be kept",
+			},
+		},
+		{
+			name: "created",
+			base: map[string]expectRepoInfo{},
+			head: map[string]expectRepoInfo{
+				"new": newRepo(community.Repository{
+					Name:     "new",
+					Branches: []community.RepoBranch{{Name: "dev", Type: community.BranchProtected}},
+				}, "bob", "alice"),
+			},
+			expect: []string{
+				"- **new**",
+				"  - create",
+				"  - add members: alice, bob",
+				"  - create branches: dev",
+				"  - protect branches: dev",
+			},
+		},
+		{
+			name: "renamed",
+			base: map[string]expectRepoInfo{"old": newRepo(community.Repository{Name: "old"})},
+			head: map[string]expectRepoInfo{
+				"new": newRepo(community.Repository{Name: "new", RenameFrom: "old"}),
+			},
+			expect: []string{
+				"- **new**",
+				"  - rename from old",
+			},
+		},
+		{
+			name: "transferred in the same org",
+			base: map[string]expectRepoInfo{"old": newRepo(community.Repository{Name: "old"})},
+			head: map[string]expectRepoInfo{
+				"new": newRepo(community.Repository{Name: "new", TransferFrom: "org/old"}),
+			},
+			expect: []string{
+				"- **new**",
+				"  - transfer from org/old",
+			},
+		},
+		{
+			name: "the owners and branches are changed",
+			base: map[string]expectRepoInfo{"kept": newRepo(community.Repository{Name: "kept"}, "alice")},
+			head: map[string]expectRepoInfo{
+				"kept": newRepo(community.Repository{
+					Name:     "kept",
+					Branches: []community.RepoBranch{{Name: "dev", CreateFrom: community.BranchMaster}},
+				}, "alice", "bob"),
+			},
+			expect: []string{
+				"- **kept**",
+				"  - add members: bob",
+				"  - remove members: carol",
+				"  - create branch: dev from master",
+			},
+		},
+		{
+			name: "frozen",
+			base: map[string]expectRepoInfo{"kept": newRepo(community.Repository{Name: "kept"})},
+			head: map[string]expectRepoInfo{
+				"kept": func() expectRepoInfo {
+					v := newRepo(community.Repository{Name: "kept", Type: "private"})
+					v.frozen = "frozen by test"
+
+					return v
+				}(),
+			},
+			expect: []string{
+				"- **kept**",
+				"  - nothing will be done, because frozen by test",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bot := &robot{cfg: &botConfig{}, cli: cli}
+
+			expect := fmt.Sprintf("The %s will do nothing after this pull request is merged.", botName)
+			if len(c.expect) > 0 {
+				expect = fmt.Sprintf(
+					"The %s will do as below after this pull request is merged.\n\n%s",
+					botName, strings.Join(c.expect, "\n"),
+				)
+			}
+
+			if v := bot.genPreview(context.Background(), c.base, c.head, local); v != expect {
+				t.Errorf("expect:\n%s\ngot:\n%s", expect, v)
+			}
+		})
+	}
+}
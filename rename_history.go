@@ -0,0 +1,185 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
+
+// renameRecordRetention is how long the pending rename which is no longer declared
+// in the repo file is kept, so that the rename chain can still be resolved.
+const renameRecordRetention = 90 * 24 * time.Hour
+
+type renameRecord struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Done means the repo has been renamed.
+	Done bool      `json:"done,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// renameHistory records the renames declared in the repo file and the ones
+// which have been done. It is used to resolve the rename chain, for example
+// the repo file declares renaming a to b first and then b to c, but a has not
+// been renamed to b yet.
+type renameHistory struct {
+	lock    sync.Mutex
+	file    string
+	records []renameRecord
+}
+
+// load loads the history from the file. The history is only kept in memory if file is empty.
+func (h *renameHistory) load(file string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.file = file
+
+	return loadJSONFile(file, &h.records)
+}
+
+// observe records the renames declared in the repo file and removes the ones which
+// are no longer needed, so that the history doesn't grow without bound.
+func (h *renameHistory) observe(repos map[string]*community.Repository, log *logrus.Entry) {
+	if len(repos) == 0 {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	changed := h.prune(repos)
+	for name, repo := range repos {
+		if from := repo.RenameFrom; from != "" && from != name && h.find(from, name) < 0 {
+			h.records = append(h.records, renameRecord{
+				From: from,
+				To:   name,
+				Time: time.Now(),
+			})
+
+			changed = true
+		}
+	}
+
+	if changed {
+		h.save(log)
+	}
+}
+
+// prune removes the renames which are not declared in the repo file any more. The done
+// ones are not needed, and the pending ones are kept for the renameRecordRetention.
+func (h *renameHistory) prune(repos map[string]*community.Repository) bool {
+	n := 0
+	for _, item := range h.records {
+		repo, ok := repos[item.To]
+		declared := ok && repo.RenameFrom == item.From
+
+		if declared || (!item.Done && time.Since(item.Time) < renameRecordRetention) {
+			h.records[n] = item
+			n++
+		}
+	}
+
+	if n == len(h.records) {
+		return false
+	}
+
+	h.records = h.records[:n]
+
+	return true
+}
+
+func (h *renameHistory) isDone(from, to string) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	i := h.find(from, to)
+
+	return i >= 0 && h.records[i].Done
+}
+
+// markDone returns true if the rename was not marked as done before.
+func (h *renameHistory) markDone(from, to string, log *logrus.Entry) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	i := h.find(from, to)
+	if i >= 0 {
+		if h.records[i].Done {
+			return false
+		}
+
+		h.records[i].Done = true
+		h.records[i].Time = time.Now()
+	} else {
+		h.records = append(h.records, renameRecord{
+			From: from,
+			To:   to,
+			Done: true,
+			Time: time.Now(),
+		})
+	}
+
+	h.save(log)
+
+	return true
+}
+
+// candidates returns the repos which the repo may be renamed from, following the
+// renames which have not been done backward, excluding the target. The nearer one
+// is in front. The repo for which stop returns true, such as the one in the repo
+// file, does not belong to the chain, so it is neither returned nor walked past.
+// The renames which have been done are not followed, because the old name may
+// be reused by a new repo.
+func (h *renameHistory) candidates(repo, target string, stop func(string) bool) []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	visited := sets.NewString(repo, target)
+	queue := []string{repo}
+	r := []string{}
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		for i := range h.records {
+			item := &h.records[i]
+			if item.Done || item.To != n || visited.Has(item.From) {
+				continue
+			}
+
+			visited.Insert(item.From)
+
+			if stop(item.From) {
+				continue
+			}
+
+			queue = append(queue, item.From)
+			r = append(r, item.From)
+		}
+	}
+
+	return r
+}
+
+func (h *renameHistory) find(from, to string) int {
+	for i := range h.records {
+		if item := &h.records[i]; item.From == from && item.To == to {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (h *renameHistory) save(log *logrus.Entry) {
+	if err := saveJSONFile(h.file, h.records); err != nil {
+		log.Errorf("save rename history, err:%s", err.Error())
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+type branchUpdate struct {
+	expect community.RepoBranch
+	actual community.RepoBranch
+}
+
+// branchDiff is the difference between the expected branches and the actual ones.
+type branchDiff struct {
+	// kept are the actual branches which are as expected.
+	kept []community.RepoBranch
+
+	// updated are the branches whose protection is not as expected.
+	updated []branchUpdate
+
+	// missing are the expected branches which do not exist.
+	missing []community.RepoBranch
+}
+
+func diffBranches(expect, actual []community.RepoBranch) branchDiff {
+	bsExpect := genBranchSets(expect)
+	bsLocal := genBranchSets(actual)
+
+	r := branchDiff{missing: bsExpect.differenceByName(&bsLocal)}
+	sort.Slice(r.missing, func(i, j int) bool {
+		return r.missing[i].Name < r.missing[j].Name
+	})
+
+	for _, name := range bsExpect.intersectionByName(&bsLocal).List() {
+		eb, lb := bsExpect.get(name), bsLocal.get(name)
+
+		if eb.Type != lb.Type {
+			r.updated = append(r.updated, branchUpdate{expect: *eb, actual: *lb})
+		} else {
+			r.kept = append(r.kept, *lb)
+		}
+	}
+
+	return r
+}
+
+// memberDiff is the difference between the expected members and the actual ones.
+type memberDiff struct {
+	// kept are the expected members which exist.
+	kept []string
+
+	// missing are the expected members which do not exist.
+	missing []string
+
+	// unexpected are the actual members which are not expected, excluding the repo owner.
+	unexpected []string
+}
+
+func diffMembers(expect, actual []string, owner string) memberDiff {
+	e := sets.NewString(expect...)
+	a := sets.NewString(actual...)
+
+	return memberDiff{
+		kept:       e.Intersection(a).List(),
+		missing:    e.Difference(a).List(),
+		unexpected: a.Difference(e).Delete(owner).List(),
+	}
+}
+
+// repoDiff is the difference between the state and the expectation of repo.
+type repoDiff struct {
+	propertyChanged bool
+	members         memberDiff
+	branches        branchDiff
+}
+
+func diffRepo(expectRepo *expectRepoInfo, s *models.RepoState) repoDiff {
+	repo := expectRepo.expectRepoState
+
+	return repoDiff{
+		propertyChanged: repo.IsPrivate() != s.Property.Private || repo.Commentable != s.Property.CanComment,
+		members:         diffMembers(expectRepo.expectOwners, s.Members, s.Owner),
+		branches:        diffBranches(expectRepo.getExpectBranches(), s.Branches),
+	}
+}
+
+func branchNames(b []community.RepoBranch) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = b[i].Name
+	}
+
+	return r
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+func TestDiffRepo(t *testing.T) {
+	master := community.RepoBranch{Name: community.BranchMaster, Type: community.BranchProtected}
+	dev := community.RepoBranch{Name: "dev"}
+
+	type expect struct {
+		propertyChanged bool
+
+		keptMembers       []string
+		missingMembers    []string
+		unexpectedMembers []string
+
+		keptBranches    []string
+		updatedBranches []string
+		missingBranches []string
+	}
+
+	cases := []struct {
+		name   string
+		repo   community.Repository
+		owners []string
+		state  models.RepoState
+		expect expect
+	}{
+		{
+			name:   "as expected",
+			repo:   community.Repository{Type: "public", Branches: []community.RepoBranch{master}},
+			owners: []string{"alice"},
+			state: models.RepoState{
+				Branches: []community.RepoBranch{master},
+				Members:  []string{"alice"},
+			},
+			expect: expect{
+				keptMembers:  []string{"alice"},
+				keptBranches: []string{community.BranchMaster},
+			},
+		},
+		{
+			name:   "the property is changed",
+			repo:   community.Repository{Type: "private", Commentable: true},
+			state:  models.RepoState{Property: models.RepoProperty{CanComment: true}},
+			expect: expect{propertyChanged: true},
+		},
+		{
+			name: "the members and branches are different",
+			repo: community.Repository{
+				Type:     "public",
+				Branches: []community.RepoBranch{master, dev},
+			},
+			owners: []string{"alice", "bob"},
+			state: models.RepoState{
+				Branches: []community.RepoBranch{{Name: community.BranchMaster}},
+				Members:  []string{"alice", "carol", "owner"},
+				Owner:    "owner",
+			},
+			expect: expect{
+				keptMembers:       []string{"alice"},
+				missingMembers:    []string{"bob"},
+				unexpectedMembers: []string{"carol"},
+				updatedBranches:   []string{community.BranchMaster},
+				missingBranches:   []string{"dev"},
+			},
+		},
+		{
+			name: "all the branches of archived repo are protected",
+			repo: community.Repository{
+				Type:     "public",
+				Status:   community.RepoStatusArchived,
+				Branches: []community.RepoBranch{dev},
+			},
+			state:  models.RepoState{Branches: []community.RepoBranch{dev}},
+			expect: expect{updatedBranches: []string{"dev"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newExpectRepoInfo("org", &c.repo, "sig", community.EffectiveOwners{Maintainers: c.owners}, nil)
+			d := diffRepo(e, &c.state)
+
+			updated := make([]string, len(d.branches.updated))
+			for i := range d.branches.updated {
+				updated[i] = d.branches.updated[i].expect.Name
+			}
+
+			v := expect{
+				propertyChanged:   d.propertyChanged,
+				keptMembers:       d.members.kept,
+				missingMembers:    d.members.missing,
+				unexpectedMembers: d.members.unexpected,
+				keptBranches:      branchNames(d.branches.kept),
+				updatedBranches:   updated,
+				missingBranches:   branchNames(d.branches.missing),
+			}
+
+			// The nil and empty slices are the same.
+			if fmt.Sprintf("%+v", v) != fmt.Sprintf("%+v", c.expect) {
+				t.Errorf("expect %+v, got %+v", c.expect, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// repoSet is a set of repos which is persisted to the file,
+// so that the repos recorded can be handled after restarting.
+type repoSet struct {
+	lock  sync.Mutex
+	file  string
+	repos sets.String
+}
+
+// load loads the repos from the file. They are only kept in memory if file is empty.
+func (t *repoSet) load(file string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.file = file
+	t.repos = sets.NewString()
+
+	var r []string
+	if err := loadJSONFile(file, &r); err != nil {
+		return err
+	}
+
+	t.repos.Insert(r...)
+
+	return nil
+}
+
+func (t *repoSet) has(repo string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.repos.Has(repo)
+}
+
+// set records whether the repo is pending.
+func (t *repoSet) set(repo string, pending bool, log *logrus.Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.repos == nil {
+		t.repos = sets.NewString()
+	}
+
+	if t.repos.Has(repo) == pending {
+		return
+	}
+
+	if pending {
+		t.repos.Insert(repo)
+	} else {
+		t.repos.Delete(repo)
+	}
+
+	t.save(log)
+}
+
+// move moves the repo when it is renamed.
+func (t *repoSet) move(from, to string, log *logrus.Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.repos.Has(from) {
+		return
+	}
+
+	t.repos.Delete(from)
+	t.repos.Insert(to)
+
+	t.save(log)
+}
+
+func (t *repoSet) save(log *logrus.Entry) {
+	if err := saveJSONFile(t.file, t.repos.List()); err != nil {
+		log.Errorf("save the repos to file:%s, err:%s", t.file, err.Error())
+	}
+}
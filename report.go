@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+const (
+	reportFormatJSON = "json"
+	reportFormatCSV  = "csv"
+	reportFormatHTML = "html"
+)
+
+var reportHTMLTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Repos managed by {{.Bot}}</title>
+<style>
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; vertical-align: top; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Repos managed by {{.Bot}}</h1>
+<p>Generated at {{.Time}}</p>
+<table>
+<tr><th>repo</th><th>sigs</th><th>type</th><th>frozen</th><th>expected branches</th><th>actual branches</th><th>expected members</th><th>actual members</th><th>reconciled at</th><th>last success</th><th>error</th></tr>
+{{range .Repos}}<tr>
+<td>{{.Repo}}</td>
+<td>{{join .Sigs ", "}}</td>
+<td>{{.Type}}</td>
+<td>{{.Frozen}}</td>
+<td>{{join .ExpectedBranches ", "}}</td>
+<td>{{join .ActualBranches ", "}}</td>
+<td>{{join .ExpectedMembers ", "}}</td>
+<td>{{join .ActualMembers ", "}}</td>
+<td>{{.ReconciledAt}}</td>
+<td>{{.LastSuccess}}</td>
+<td class="error">{{.Error}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type repoReport struct {
+	Repo             string   `json:"repo"`
+	Sigs             []string `json:"sigs,omitempty"`
+	Type             string   `json:"type"`
+	Frozen           string   `json:"frozen,omitempty"`
+	ExpectedBranches []string `json:"expected_branches,omitempty"`
+	ActualBranches   []string `json:"actual_branches,omitempty"`
+	ExpectedMembers  []string `json:"expected_members,omitempty"`
+	ActualMembers    []string `json:"actual_members,omitempty"`
+	ReconciledAt     string   `json:"reconciled_at,omitempty"`
+	LastSuccess      string   `json:"last_success,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// repoReports collects the expected repos. A repo may belong to several sigs.
+type repoReports map[string]*repoReport
+
+func (r repoReports) add(expectRepo *expectRepoInfo) {
+	name := expectRepo.getNewRepoName()
+
+	if v, ok := r[name]; ok {
+		if expectRepo.sig != "" {
+			v.Sigs = append(v.Sigs, expectRepo.sig)
+		}
+		return
+	}
+
+	repo := expectRepo.expectRepoState
+
+	v := &repoReport{
+		Repo:             name,
+		Type:             repo.Type,
+		Frozen:           expectRepo.frozen,
+		ExpectedBranches: formatBranches(repo.Branches),
+		ExpectedMembers:  sortedStrings(expectRepo.expectOwners),
+	}
+	if expectRepo.sig != "" {
+		v.Sigs = []string{expectRepo.sig}
+	}
+
+	r[name] = v
+}
+
+// gen generates the reports with the actual state of repos. The branches will be
+// listed by listBranches if it is not nil, otherwise the ones in the local state are used.
+func (r repoReports) gen(
+	local *localState,
+	listBranches func(string) ([]community.RepoBranch, error),
+) []repoReport {
+	items := make([]repoReport, 0, len(r))
+
+	for name, v := range r {
+		item := *v
+
+		if lr, ok := local.repos[name]; ok {
+			s := lr.State()
+
+			branches := s.Branches
+			if listBranches != nil && s.Available {
+				if b, err := listBranches(name); err != nil {
+					item.Error = fmt.Sprintf("list branches, err:%s", err.Error())
+				} else {
+					branches = b
+				}
+			}
+
+			item.ActualBranches = formatBranches(branches)
+			item.ActualMembers = sortedStrings(s.Members)
+
+			if res := lr.Result(); !res.LastAttempt.IsZero() {
+				item.ReconciledAt = res.LastAttempt.Format(time.RFC3339)
+
+				if !res.LastSuccess.IsZero() {
+					item.LastSuccess = res.LastSuccess.Format(time.RFC3339)
+				}
+
+				errs := make([]string, len(res.Errors))
+				for i := range res.Errors {
+					errs[i] = res.Errors[i].Step + ": " + res.Errors[i].Error
+				}
+				item.Error = strings.Join(errs, "; ")
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Repo < items[j].Repo
+	})
+
+	return items
+}
+
+func formatBranches(b []community.RepoBranch) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = b[i].Name
+		if b[i].Type == community.BranchProtected {
+			r[i] += "(protected)"
+		}
+	}
+	sort.Strings(r)
+
+	return r
+}
+
+// reportSnapshot is the expected repos of last check. The reports are generated with
+// the latest state of repos on request, so that they include the results of queued tasks.
+type reportSnapshot struct {
+	lock    sync.RWMutex
+	reports repoReports
+	local   localState
+}
+
+func (s *reportSnapshot) set(reports repoReports, local *localState) {
+	// The repos are copied, because the local state may be changed by the next check.
+	v := localState{repos: make(map[string]*models.Repo, len(reports))}
+	for k := range reports {
+		if r, ok := local.repos[k]; ok {
+			v.repos[k] = r
+		}
+	}
+
+	s.lock.Lock()
+	s.reports = reports
+	s.local = v
+	s.lock.Unlock()
+}
+
+func (s *reportSnapshot) get() []repoReport {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.reports == nil {
+		return nil
+	}
+
+	return s.reports.gen(&s.local, nil)
+}
+
+func writeReport(w io.Writer, format string, items []repoReport) error {
+	switch format {
+	case reportFormatJSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+
+		return e.Encode(items)
+
+	case reportFormatCSV:
+		cw := csv.NewWriter(w)
+
+		cw.Write([]string{
+			"repo", "sigs", "type", "frozen", "expected branches", "actual branches",
+			"expected members", "actual members", "reconciled at", "last success", "error",
+		})
+
+		for i := range items {
+			item := &items[i]
+			cw.Write([]string{
+				item.Repo,
+				strings.Join(item.Sigs, " "),
+				item.Type,
+				item.Frozen,
+				strings.Join(item.ExpectedBranches, " "),
+				strings.Join(item.ActualBranches, " "),
+				strings.Join(item.ExpectedMembers, " "),
+				strings.Join(item.ActualMembers, " "),
+				item.ReconciledAt,
+				item.LastSuccess,
+				item.Error,
+			})
+		}
+
+		cw.Flush()
+
+		return cw.Error()
+
+	case reportFormatHTML:
+		return reportHTMLTemplate.Execute(w, map[string]interface{}{
+			"Bot":   botName,
+			"Time":  time.Now().Format(time.RFC3339),
+			"Repos": items,
+		})
+
+	default:
+		return fmt.Errorf("unknown report format:%s", format)
+	}
+}
+
+// genReport generates the report once. The reconciled time and error are the
+// ones persisted in the checkpoint by the watcher at its last check.
+func (bot *robot) genReport(format, output string, log *logrus.Entry) error {
+	ctx := context.Background()
+	reports := repoReports{}
+
+	org, err := bot.checkExpectation(ctx, bot.cfg.WatchingFiles.repoBranch, log, reports.add)
+	if err != nil {
+		return err
+	}
+
+	local, err := bot.loadALLRepos(ctx, org)
+	if err != nil {
+		return err
+	}
+
+	bot.restoreResults(local, log)
+
+	items := reports.gen(local, func(repo string) ([]community.RepoBranch, error) {
+		return bot.listAllBranchOfRepo(ctx, org, repo)
+	})
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	return writeReport(w, format, items)
+}
+
+// serveAdmin serves the report of the repos with their latest state, such as /report?format=html.
+func (bot *robot) serveAdmin(ctx context.Context, addr string, log *logrus.Entry) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = reportFormatJSON
+		}
+
+		switch format {
+		case reportFormatJSON:
+			w.Header().Set("Content-Type", "application/json")
+		case reportFormatCSV:
+			w.Header().Set("Content-Type", "text/csv")
+		case reportFormatHTML:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		default:
+			http.Error(w, "unknown format", http.StatusBadRequest)
+			return
+		}
+
+		if err := writeReport(w, format, bot.reports.get()); err != nil {
+			log.Errorf("write report, err:%s", err.Error())
+		}
+	})
+
+	s := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+
+	if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Errorf("serve admin, err:%s", err.Error())
+	}
+}
@@ -1,42 +1,133 @@
 package main
 
 import (
+	"context"
 	"sync"
 
 	sdk "gitee.com/openeuler/go-gitee/gitee"
-	"github.com/panjf2000/ants/v2"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const botName = "repo-watcher"
 
 type iClient interface {
-	GetRef(org, repo, ref string) (string, error)
-	GetRepo(org, repo string) (sdk.Project, error)
-	GetRepos(org string) ([]sdk.Project, error)
-	CreateRepo(org string, repo sdk.RepositoryPostParam) error
-	UpdateRepo(org, repo string, info sdk.RepoPatchParam) error
-	SetRepoReviewer(org, repo string, reviewer sdk.SetRepoReviewer) error
-
-	GetPathContent(org, repo, path, ref string) (sdk.Content, error)
-	CreateFile(org, repo, branch, path, content, commitMsg string) (sdk.CommitContent, error)
-	GetDirectoryTree(org, repo, sha string, recursive int32) (sdk.Tree, error)
-
-	RemoveRepoMember(org, repo, login string) error
-	AddRepoMember(org, repo, login, permission string) error
-
-	GetRepoAllBranch(org, repo string) ([]sdk.Branch, error)
-	CreateBranch(org, repo, branch, parentBranch string) error
-	SetProtectionBranch(org, repo, branch string) error
-	CancelProtectionBranch(org, repo, branch string) error
+	GetRef(ctx context.Context, org, repo, ref string) (string, error)
+	GetRepo(ctx context.Context, org, repo string) (sdk.Project, error)
+	GetRepos(ctx context.Context, org string) ([]sdk.Project, error)
+	CreateRepo(ctx context.Context, org string, repo sdk.RepositoryPostParam) error
+	ImportRepo(ctx context.Context, org string, param importRepoParam) error
+	ForkRepo(ctx context.Context, org, repo, newOrg, newRepo string) error
+	UpdateRepo(ctx context.Context, org, repo string, info sdk.RepoPatchParam) error
+	GetUser(ctx context.Context, login string) (userInfo, error)
+	TransferRepo(ctx context.Context, org, repo, newOrg string) error
+	SetRepoReviewer(ctx context.Context, org, repo string, reviewer sdk.SetRepoReviewer) error
+
+	GetPathContent(ctx context.Context, org, repo, path, ref string) (sdk.Content, error)
+	CreateFile(ctx context.Context, org, repo, branch, path, content, commitMsg string) (sdk.CommitContent, error)
+	UpdateFile(ctx context.Context, org, repo, branch, path, content, sha, commitMsg string) error
+	DeleteFile(ctx context.Context, org, repo, branch, path, sha, commitMsg string) error
+	GetDirectoryTree(ctx context.Context, org, repo, sha string, recursive int32) (sdk.Tree, error)
+
+	RemoveRepoMember(ctx context.Context, org, repo, login string) error
+	AddRepoMember(ctx context.Context, org, repo, login, permission string) error
+
+	GetRepoAllBranch(ctx context.Context, org, repo string) ([]sdk.Branch, error)
+	CreateBranch(ctx context.Context, org, repo, branch, parentBranch string) error
+	SetProtectionBranch(ctx context.Context, org, repo, branch string) error
+	CancelProtectionBranch(ctx context.Context, org, repo, branch string) error
+
+	ListPullRequests(ctx context.Context, org, repo, state, head, base string) ([]pullRequest, error)
+	GetPullRequest(ctx context.Context, org, repo string, number int32) (pullRequest, error)
+	CreatePullRequest(ctx context.Context, org, repo string, param pullRequestParam) (pullRequest, error)
+	UpdatePullRequest(ctx context.Context, org, repo string, number int32, param pullRequestParam) error
+
+	CreateIssueComment(ctx context.Context, org, repo, number, comment string) error
+	GetCommit(ctx context.Context, org, repo, sha string) (commitInfo, error)
+	AddPRComment(ctx context.Context, org, repo string, number int32, comment string) (int32, error)
+	EditPRComment(ctx context.Context, org, repo string, commentID int32, comment string) error
+	ListPRComments(ctx context.Context, org, repo string, number int32) ([]prCommentInfo, error)
+
+	ListRepoLabels(ctx context.Context, org, repo string) ([]repoLabel, error)
+	CreateRepoLabel(ctx context.Context, org, repo string, label repoLabel) error
+
+	ListRepoWebhooks(ctx context.Context, org, repo string) ([]repoWebhook, error)
+	CreateRepoWebhook(ctx context.Context, org, repo string, hook repoWebhook) error
+
+	ListTeams(ctx context.Context, org string) ([]team, error)
+	CreateTeam(ctx context.Context, org, name, desc string) error
+	ListTeamMembers(ctx context.Context, org, teamName string) ([]string, error)
+	AddTeamMember(ctx context.Context, org, teamName, login string) error
+	RemoveTeamMember(ctx context.Context, org, teamName, login string) error
+	ListTeamRepos(ctx context.Context, org, teamName string) ([]string, error)
+	AddTeamRepo(ctx context.Context, org, teamName, repo, permission string) error
+	RemoveTeamRepo(ctx context.Context, org, teamName, repo string) error
 }
 
-func newRobot(cli iClient, pool *ants.Pool, cfg *botConfig) *robot {
-	return &robot{cli: cli, pool: pool, cfg: cfg}
+func newRobot(cli iClient, cfg *botConfig) *robot {
+	bot := &robot{
+		cli:   cli,
+		cfg:   cfg,
+		tasks: runningTasks{repos: sets.NewString()},
+	}
+
+	bot.hooks = bot.newRepoHooks()
+
+	return bot
 }
 
 type robot struct {
-	pool *ants.Pool
-	cfg  *botConfig
-	cli  iClient
-	wg   sync.WaitGroup
+	cfg   *botConfig
+	cli   iClient
+	wg    sync.WaitGroup
+	queue *repoQueue
+	tasks runningTasks
+	hooks repoHooks
+
+	renames     renameHistory
+	statuses    statusHistory
+	obsProjects obsProjectCache
+	obsChanges  obsFileChanges
+	sigTeams    map[string]*sigTeamState
+	events      changeEvents
+	feedback    prFeedbacks
+	reports     reportSnapshot
+
+	// expectedRepos are the repos in the repo file at the last check and their expectations.
+	expectedRepos map[string]*expectRepoInfo
+
+	// templates are the new repos which have not been seeded from the template repo completely.
+	templates repoSet
+
+	// failedHooks are the hooks of each repo which failed or were not run.
+	// They are run again when the repo is handled next time.
+	failedHooks hookFailures
+
+	// interrupted are the repos whose tasks were interrupted when exiting.
+	// They are handled first next time.
+	interrupted repoSet
+}
+
+// runningTasks records the repos which are being handled.
+type runningTasks struct {
+	lock  sync.Mutex
+	repos sets.String
+}
+
+func (t *runningTasks) add(repo string) {
+	t.lock.Lock()
+	t.repos.Insert(repo)
+	t.lock.Unlock()
+}
+
+func (t *runningTasks) remove(repo string) {
+	t.lock.Lock()
+	t.repos.Delete(repo)
+	t.lock.Unlock()
+}
+
+func (t *runningTasks) list() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.repos.List()
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// repoStatusRecord is the lifecycle status whose policy has been applied to the repo.
+type repoStatusRecord struct {
+	Status string `json:"status"`
+
+	// ProtectedBranches are the branches which are protected when applying the policy.
+	// They will be unprotected when the repo is reactivated.
+	ProtectedBranches []string  `json:"protected_branches,omitempty"`
+	Time              time.Time `json:"time"`
+}
+
+// statusHistory records the lifecycle status applied to each repo.
+// The repo which is not recorded is treated as an active one.
+type statusHistory struct {
+	lock    sync.Mutex
+	file    string
+	records map[string]repoStatusRecord
+}
+
+// load loads the history from the file. The history is only kept in memory if file is empty.
+func (h *statusHistory) load(file string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.file = file
+	h.records = make(map[string]repoStatusRecord)
+
+	return loadJSONFile(file, &h.records)
+}
+
+func (h *statusHistory) get(repo string) repoStatusRecord {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.records[repo]
+}
+
+// set records the status of repo. The record is removed if the repo
+// is active and there is nothing to restore.
+func (h *statusHistory) set(repo string, r repoStatusRecord, log *logrus.Entry) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.records == nil {
+		h.records = make(map[string]repoStatusRecord)
+	}
+
+	if statusPolicy(r.Status) == statusPolicy("") && len(r.ProtectedBranches) == 0 {
+		if _, ok := h.records[repo]; !ok {
+			return
+		}
+
+		delete(h.records, repo)
+	} else {
+		r.Time = time.Now()
+		h.records[repo] = r
+	}
+
+	h.save(log)
+}
+
+// move moves the record when the repo is renamed.
+func (h *statusHistory) move(from, to string, log *logrus.Entry) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	r, ok := h.records[from]
+	if !ok {
+		return
+	}
+
+	delete(h.records, from)
+	h.records[to] = r
+
+	h.save(log)
+}
+
+func (h *statusHistory) save(log *logrus.Entry) {
+	if err := saveJSONFile(h.file, h.records); err != nil {
+		log.Errorf("save status history, err:%s", err.Error())
+	}
+}
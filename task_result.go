@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+const (
+	stepCreate     = "create"
+	stepRename     = "rename"
+	stepTransfer   = "transfer"
+	stepBranch     = "branch"
+	stepMember     = "member"
+	stepProperty   = "property"
+	stepTemplate   = "template"
+	stepOwnersFile = "owners_file"
+	stepHook       = "hook"
+	stepOBSProject = "obs_meta_project"
+	stepStatus     = "status"
+	stepVerify     = "verify"
+)
+
+// taskResult collects the errors of steps when handling a repo once.
+// The steps of a task run in sequence, so it is not protected by lock.
+type taskResult struct {
+	errors []models.StepError
+}
+
+func (r *taskResult) fail(step string, err error) {
+	if r == nil || err == nil {
+		return
+	}
+
+	r.errors = append(r.errors, models.StepError{Step: step, Error: err.Error()})
+}
+
+func (r *taskResult) failf(step, format string, args ...interface{}) {
+	r.fail(step, fmt.Errorf(format, args...))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
+
+// repoTemplateData is the data which can be referenced by the templates.
+type repoTemplateData struct {
+	Org         string
+	Name        string
+	Description string
+	Type        string
+	Branches    []community.RepoBranch
+
+	// Sig is the sig which the repo belongs to. It may be empty.
+	Sig         string
+	Maintainers []string
+	Committers  []string
+
+	// Branch is the branch which the file is generated for.
+	Branch string
+}
+
+func newRepoTemplateData(expectRepo *expectRepoInfo, branch string) repoTemplateData {
+	repo := expectRepo.expectRepoState
+
+	return repoTemplateData{
+		Org:         expectRepo.org,
+		Name:        repo.Name,
+		Description: repo.Description,
+		Type:        repo.Type,
+		Branches:    repo.Branches,
+		Sig:         expectRepo.sig,
+		Maintainers: expectRepo.getMaintainers(),
+		Committers:  expectRepo.committers,
+		Branch:      branch,
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func parseTemplateFile(p string) (*template.Template, error) {
+	v, err := newTemplate(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTemplate(filepath.Base(p), v)
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template:%s, err:%s", name, err.Error())
+	}
+
+	return t, nil
+}
+
+func execTemplate(t *template.Template, data interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
+
+// userState is the result of resolving a user.
+type userState int
+
+const (
+	userResolved userState = iota
+	userNotFound
+	// userUnknown means the user fails to be looked up, and it is unknown
+	// whether the user exists.
+	userUnknown
+)
+
+type cachedUser struct {
+	// login is empty if the user does not exist.
+	login string
+	time  time.Time
+}
+
+// userResolver resolves the owners declared in the community files to the
+// gitee logins and validates them. The results are cached for ttl.
+type userResolver struct {
+	cli iClient
+	ttl time.Duration
+
+	// The resolver is shared by the watch, preview and report.
+	lock  sync.Mutex
+	cache map[string]cachedUser
+}
+
+func newUserResolver(cli iClient, ttl time.Duration) *userResolver {
+	return &userResolver{
+		cli:   cli,
+		ttl:   ttl,
+		cache: make(map[string]cachedUser),
+	}
+}
+
+// resolve returns the gitee login of the id which may be a login, email or alternate id.
+// The failure of looking up is not cached, so that it will be looked up again next time.
+// The last result is used if there is, otherwise the declared login is returned with
+// userUnknown, so that the owner will not be removed because of a transient error.
+func (r *userResolver) resolve(
+	ctx context.Context, id string, identities *community.Identities,
+) (string, userState) {
+	login := identities.GetLogin(id)
+	if login == "" {
+		login = strings.ToLower(id)
+	}
+
+	v, cached := r.getCache(login)
+	if cached && time.Since(v.time) < r.ttl {
+		return v.result()
+	}
+
+	u, err := r.cli.GetUser(ctx, login)
+	if err != nil {
+		if isNotFound(err) {
+			r.setCache(login, "")
+
+			return "", userNotFound
+		}
+
+		if cached {
+			return v.result()
+		}
+
+		return login, userUnknown
+	}
+
+	v.login = login
+	if u.Login != "" {
+		v.login = strings.ToLower(u.Login)
+	}
+
+	r.setCache(login, v.login)
+
+	return v.login, userResolved
+}
+
+func (r *userResolver) getCache(login string) (cachedUser, bool) {
+	r.lock.Lock()
+	v, ok := r.cache[login]
+	r.lock.Unlock()
+
+	return v, ok
+}
+
+func (r *userResolver) setCache(key, login string) {
+	r.lock.Lock()
+	r.cache[key] = cachedUser{login: login, time: time.Now()}
+	r.lock.Unlock()
+}
+
+func (u cachedUser) result() (string, userState) {
+	if u.login == "" {
+		return "", userNotFound
+	}
+
+	return u.login, userResolved
+}
+
+// resolveOwners returns the resolved owners and the ones which don't exist.
+// The owners which fail to be looked up are kept as declared.
+func (r *userResolver) resolveOwners(
+	ctx context.Context, owners community.EffectiveOwners, identities *community.Identities,
+) (community.EffectiveOwners, []string) {
+	var unresolved []string
+
+	f := func(ids []string) sets.String {
+		s := sets.NewString()
+		for _, id := range ids {
+			if login, state := r.resolve(ctx, id, identities); state == userNotFound {
+				unresolved = append(unresolved, id)
+			} else {
+				s.Insert(login)
+			}
+		}
+
+		return s
+	}
+
+	m := f(owners.Maintainers)
+	c := f(owners.Committers)
+
+	return community.EffectiveOwners{
+		Maintainers: m.List(),
+		Committers:  c.Difference(m).List(),
+	}, unresolved
+}
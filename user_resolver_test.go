@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/community"
+)
+
+// fakeUserClient returns the user or the error of each login.
+type fakeUserClient struct {
+	iClient
+
+	users map[string]userInfo
+	errs  map[string]error
+	calls int
+}
+
+func (c *fakeUserClient) GetUser(ctx context.Context, login string) (userInfo, error) {
+	c.calls++
+
+	if err, ok := c.errs[login]; ok {
+		return userInfo{}, err
+	}
+
+	if u, ok := c.users[login]; ok {
+		return u, nil
+	}
+
+	return userInfo{}, &httpStatusError{statusCode: http.StatusNotFound, msg: "404 Not Found"}
+}
+
+func TestUserResolverResolve(t *testing.T) {
+	errTransient := errors.New("timeout")
+
+	cases := []struct {
+		name        string
+		id          string
+		users       map[string]userInfo
+		errs        map[string]error
+		cache       map[string]cachedUser
+		expectLogin string
+		expectState userState
+	}{
+		{
+			name:        "resolved",
+			id:          "Alice",
+			users:       map[string]userInfo{"alice": {Login: "Alice"}},
+			expectLogin: "alice",
+			expectState: userResolved,
+		},
+		{
+			name:        "not found",
+			id:          "bob",
+			expectState: userNotFound,
+		},
+		{
+			name:        "unknown is kept as declared",
+			id:          "Carol",
+			errs:        map[string]error{"carol": errTransient},
+			expectLogin: "carol",
+			expectState: userUnknown,
+		},
+		{
+			name:        "the expired cache is used when failed",
+			id:          "dave",
+			errs:        map[string]error{"dave": errTransient},
+			cache:       map[string]cachedUser{"dave": {login: "dave", time: time.Now().Add(-2 * time.Hour)}},
+			expectLogin: "dave",
+			expectState: userResolved,
+		},
+		{
+			name:        "the cache which is not expired is used",
+			id:          "erin",
+			cache:       map[string]cachedUser{"erin": {time: time.Now()}},
+			expectState: userNotFound,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cli := &fakeUserClient{users: c.users, errs: c.errs}
+
+			r := newUserResolver(cli, time.Hour)
+			for k, v := range c.cache {
+				r.cache[k] = v
+			}
+
+			login, state := r.resolve(context.Background(), c.id, &community.Identities{})
+			if login != c.expectLogin || state != c.expectState {
+				t.Errorf(
+					"expect %s(%d), got %s(%d)",
+					c.expectLogin, c.expectState, login, state,
+				)
+			}
+		})
+	}
+}
+
+func TestUserResolverCache(t *testing.T) {
+	cli := &fakeUserClient{
+		users: map[string]userInfo{"alice": {Login: "Alice"}},
+		errs:  map[string]error{"carol": errors.New("timeout")},
+	}
+	r := newUserResolver(cli, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		for _, id := range []string{"alice", "bob", "carol"} {
+			r.resolve(context.Background(), id, &community.Identities{})
+		}
+	}
+
+	// The failure of carol is not cached.
+	if cli.calls != 4 {
+		t.Errorf("expect 4 calls, got %d", cli.calls)
+	}
+}
+
+func TestUserResolverResolveOwners(t *testing.T) {
+	cli := &fakeUserClient{
+		users: map[string]userInfo{"alice": {Login: "alice"}, "bob": {Login: "bob"}},
+		errs:  map[string]error{"carol": errors.New("timeout")},
+	}
+	r := newUserResolver(cli, time.Hour)
+
+	owners, unresolved := r.resolveOwners(
+		context.Background(),
+		community.EffectiveOwners{
+			Maintainers: []string{"alice", "dave"},
+			Committers:  []string{"alice", "bob", "carol"},
+		},
+		&community.Identities{},
+	)
+
+	expect := community.EffectiveOwners{
+		Maintainers: []string{"alice"},
+		Committers:  []string{"bob", "carol"},
+	}
+	if !reflect.DeepEqual(owners, expect) {
+		t.Errorf("expect %v, got %v", expect, owners)
+	}
+
+	if !reflect.DeepEqual(unresolved, []string{"dave"}) {
+		t.Errorf("expect [dave] to be unresolved, got %v", unresolved)
+	}
+}
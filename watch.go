@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/opensourceways/robot-gitee-repo-watcher/community"
 	"github.com/opensourceways/robot-gitee-repo-watcher/models"
@@ -13,13 +15,46 @@ import (
 type expectRepoInfo struct {
 	expectRepoState *community.Repository
 	expectOwners    []string
+	committers      []string
+	sig             string
 	org             string
+
+	// allExpectRepos are all the repos in the repo file.
+	allExpectRepos map[string]*community.Repository
+
+	// result collects the errors of steps when handling the repo.
+	result *taskResult
+
+	// frozen is the reason why the repo can't be changed. It is empty if the repo can be changed.
+	frozen string
 }
 
 func (e *expectRepoInfo) getNewRepoName() string {
 	return e.expectRepoState.Name
 }
 
+func newExpectRepoInfo(
+	org string,
+	repo *community.Repository,
+	sig string,
+	owners community.EffectiveOwners,
+	allExpectRepos map[string]*community.Repository,
+) *expectRepoInfo {
+	return &expectRepoInfo{
+		org:             org,
+		expectOwners:    owners.GetOwners(),
+		committers:      owners.Committers,
+		sig:             sig,
+		allExpectRepos:  allExpectRepos,
+		expectRepoState: repo,
+	}
+}
+
+// getMaintainers returns the owners who are not committers.
+func (e *expectRepoInfo) getMaintainers() []string {
+	return sets.NewString(e.expectOwners...).Difference(sets.NewString(e.committers...)).List()
+}
+
 func (bot *robot) run(ctx context.Context, log *logrus.Entry) error {
 	w := &bot.cfg.WatchingFiles
 	expect := &expectState{
@@ -27,30 +62,214 @@ func (bot *robot) run(ctx context.Context, log *logrus.Entry) error {
 		log:       log,
 		cli:       bot.cli,
 		sigOwners: make(map[string]*expectSigOwners),
+		users:     newUserResolver(bot.cli, bot.cfg.userCacheTTL()),
+	}
+
+	org, err := expect.init(
+		ctx, w.RepoFilePath, w.SigFilePath, w.SigDir, w.IdentityFilePath, w.PeerRepoFilePaths,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := bot.renames.load(bot.cfg.RenameHistoryFile); err != nil {
+		return err
+	}
+
+	if err := bot.statuses.load(bot.cfg.StatusHistoryFile); err != nil {
+		return err
+	}
+
+	if err := bot.templates.load(bot.cfg.RepoTemplate.PendingFile); err != nil {
+		return err
+	}
+
+	if err := bot.interrupted.load(bot.cfg.InterruptedTasksFile); err != nil {
+		return err
 	}
 
-	org, err := expect.init(w.RepoFilePath, w.SigFilePath, w.SigDir)
+	if err := bot.failedHooks.load(bot.cfg.FailedHooksFile); err != nil {
+		return err
+	}
+
+	local, err := bot.loadALLRepos(ctx, org)
 	if err != nil {
 		return err
 	}
 
-	local, err := bot.loadALLRepos(org)
+	if addr := bot.cfg.AdminAddress; addr != "" {
+		go bot.serveAdmin(ctx, addr, log)
+	}
+
+	cfg := &bot.cfg.LeaderElection
+	if !cfg.Enable {
+		bot.lead(ctx, ctx, org, local, expect, log)
+
+		return nil
+	}
+
+	lock, err := newLeaderLock(cfg)
 	if err != nil {
 		return err
 	}
 
-	bot.watch(ctx, org, local, expect)
+	elector := leaderElector{cfg: cfg, lock: lock, log: log}
+
+	warm := warmUpTask{last: time.Now()}
+	elector.run(
+		ctx,
+		func(leaderCtx context.Context) {
+			// The caches are shared with the warm up, so it must exit before leading.
+			warm.stop()
+
+			bot.lead(ctx, leaderCtx, org, local, expect, log)
+		},
+		func() {
+			warm.start(ctx, cfg.warmUpInterval(), func(ctx context.Context) {
+				bot.warmUp(ctx, org, local, expect, log)
+			})
+		},
+	)
+
+	warm.stop()
+
 	return nil
 }
 
-func (bot *robot) watch(ctx context.Context, org string, local *localState, expect *expectState) {
+// warmUpTask runs the warm up in background, so that it doesn't delay taking over.
+type warmUpTask struct {
+	last   time.Time
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start starts the warm up if the interval has elapsed since the last one,
+// and the last one has finished.
+func (t *warmUpTask) start(ctx context.Context, interval time.Duration, f func(context.Context)) {
+	if t.done != nil {
+		select {
+		case <-t.done:
+		default:
+			return
+		}
+	}
+
+	if time.Since(t.last) < interval {
+		return
+	}
+	t.last = time.Now()
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	t.cancel = cancel
+	t.done = done
+
+	go func() {
+		defer close(done)
+
+		f(ctx)
+	}()
+}
+
+// stop cancels the running warm up and waits for it to exit.
+func (t *warmUpTask) stop() {
+	if t.done == nil {
+		return
+	}
+
+	t.cancel()
+	<-t.done
+
+	t.done = nil
+}
+
+// lead watches the repos until the leaderCtx is done.
+func (bot *robot) lead(
+	ctx, leaderCtx context.Context,
+	org string,
+	local *localState,
+	expect *expectState,
+	log *logrus.Entry,
+) {
+	// The tasks will not be cancelled at once when receiving the exit signal,
+	// they have chance to finish within the shutdown timeout.
+	taskCtx, cancelTasks := context.WithCancel(context.Background())
+	defer cancelTasks()
+
+	// It lost the leadership if the leaderCtx is done but the ctx is not. Stop the tasks
+	// and the steps after checking at once to avoid racing with the new leader.
+	go func() {
+		select {
+		case <-leaderCtx.Done():
+			if !isCancelled(ctx) {
+				cancelTasks()
+			}
+		case <-taskCtx.Done():
+		}
+	}()
+
+	// The teams may be changed by the other leader.
+	bot.sigTeams = nil
+
+	// The repos may be handled by the other leader or before restarting.
+	bot.restoreResults(local, log)
+
+	bot.queue = newRepoQueue(&bot.cfg.WorkQueue)
+	bot.startWorkers(taskCtx)
+
+	bot.watch(leaderCtx, taskCtx, org, local, expect)
+
+	if v := bot.queue.shutDown(); len(v) > 0 {
+		log.Infof("the repos waiting in the queue will be handled next time, repos:%s", strings.Join(v, ", "))
+	}
+
+	bot.drain(cancelTasks, log)
+
+	// The changes are not submitted if the tasks are cancelled,
+	// and they will be staged again by the next leader.
+	bot.submitOBSMetaChanges(taskCtx, log)
+}
+
+// warmUp refreshes the caches of standby replica, so that it can take over quickly.
+func (bot *robot) warmUp(
+	ctx context.Context,
+	org string,
+	local *localState,
+	expect *expectState,
+	log *logrus.Entry,
+) {
+	expect.check(
+		ctx,
+		org,
+		func() bool { return isCancelled(ctx) },
+		func(func(string) bool) {},
+		func(*community.Repository, string, community.EffectiveOwners, *logrus.Entry) {},
+		nil,
+	)
+
+	if isCancelled(ctx) {
+		return
+	}
+
+	v, err := bot.loadALLRepos(ctx, org)
+	if err != nil {
+		log.Errorf("warm up and load all repos, err:%s", err.Error())
+	} else {
+		local.repos = v.repos
+	}
+}
+
+func (bot *robot) watch(ctx, taskCtx context.Context, org string, local *localState, expect *expectState) {
 	if interval := bot.cfg.Interval; interval <= 0 {
 		for {
 			if isCancelled(ctx) {
 				break
 			}
 
-			bot.checkOnce(ctx, org, local, expect)
+			bot.checkOnce(ctx, taskCtx, org, local, expect)
+
+			bot.queue.waitIdle(ctx)
 		}
 	} else {
 		t := time.Duration(interval) * time.Minute
@@ -62,35 +281,64 @@ func (bot *robot) watch(ctx context.Context, org string, local *localState, expe
 
 			s := time.Now()
 
-			bot.checkOnce(ctx, org, local, expect)
+			bot.checkOnce(ctx, taskCtx, org, local, expect)
 
 			e := time.Now()
 			if v := e.Sub(s); v < t {
-				time.Sleep(t - v)
+				sleep(ctx, t-v)
 			}
 		}
 	}
+}
+
+// drain waits for the running tasks to finish. The tasks which are still
+// running after the shutdown timeout will be cancelled and recorded as incomplete.
+func (bot *robot) drain(cancelTasks context.CancelFunc, log *logrus.Entry) {
+	done := make(chan struct{})
+	go func() {
+		bot.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(bot.cfg.shutdownTimeout()):
+	}
 
-	bot.wg.Wait()
+	incomplete := bot.tasks.list()
+
+	cancelTasks()
+	<-done
+
+	log.Errorf(
+		"the tasks of repos are incomplete when exiting, they will be handled first next time, repos:%s",
+		strings.Join(incomplete, ", "),
+	)
 }
 
-func (bot *robot) checkOnce(ctx context.Context, org string, local *localState, expect *expectState) {
-	f := func(repo *community.Repository, owners []string, log *logrus.Entry) {
+func (bot *robot) checkOnce(ctx, taskCtx context.Context, org string, local *localState, expect *expectState) {
+	reports := repoReports{}
+	queued := sets.NewString()
+
+	f := func(repo *community.Repository, sig string, owners community.EffectiveOwners, log *logrus.Entry) {
 		if repo == nil {
 			return
 		}
 
-		err := bot.execTask(
-			local.getOrNewRepo(repo.Name),
-			expectRepoInfo{
-				org:             org,
-				expectOwners:    owners,
-				expectRepoState: repo,
-			},
-			log,
-		)
-		if err != nil {
-			log.Errorf("submit task of repo:%s, err:%s", repo.Name, err.Error())
+		expectRepo := newExpectRepoInfo(org, repo, sig, owners, expect.getRepos())
+		expectRepo.frozen = expect.getFrozenReason(repo, sig)
+		reports.add(expectRepo)
+
+		if expectRepo.frozen != "" {
+			bot.reportFrozenRepo(local.getOrNewRepo(repo.Name), expectRepo, log)
+			return
+		}
+
+		if err := bot.execTask(local.getOrNewRepo(repo.Name), *expectRepo, log); err != nil {
+			log.Errorf("queue the repo:%s, err:%s", repo.Name, err.Error())
+		} else {
+			queued.Insert(repo.Name)
 		}
 	}
 
@@ -100,34 +348,190 @@ func (bot *robot) checkOnce(ctx context.Context, org string, local *localState,
 
 	expect.log.Info("new check")
 
-	expect.check(org, isStopped, local.clear, f)
+	expect.check(ctx, org, isStopped, local.clear, f, func(c *repoChanges) {
+		bot.trackChangedRepos(taskCtx, &expect.w, c, expect.log)
+	})
+
+	// The repos which are not queued by a complete check are no longer expected.
+	if !isStopped() && queued.Len() > 0 {
+		bot.queue.retain(queued)
+	}
+
+	bot.reports.set(reports, local)
+
+	repos := expect.getRepos()
+
+	bot.renames.observe(repos, expect.log)
+
+	if !isStopped() && len(repos) > 0 {
+		bot.feedback.drop(func(repo string) bool {
+			_, ok := repos[repo]
+			return ok
+		})
+	}
+
+	bot.handleRemovedRepos(taskCtx, org, local, expect)
+
+	bot.handleSigTeams(taskCtx, org, expect.sigs, expect.log)
+
+	// The changes made by the tasks which are still running will be submitted next time.
+	bot.submitOBSMetaChanges(taskCtx, expect.log)
+
+	events := bot.events.take()
+
+	bot.sendNotifications(taskCtx, org, events, expect.log)
+
+	bot.submitPRFeedback(taskCtx, &expect.w, events, expect.log)
 }
 
+// execTask queues the repo in the lane decided by what will be done to it.
 func (bot *robot) execTask(localRepo *models.Repo, expectRepo expectRepoInfo, log *logrus.Entry) error {
+	return bot.queue.add(
+		expectRepo.getNewRepoName(),
+		&repoTask{localRepo: localRepo, expectRepo: expectRepo, log: log},
+		bot.laneOf(localRepo, &expectRepo),
+	)
+}
+
+// laneOf returns the lane of repo. The new repos including the ones which will be
+// renamed or transferred to, and the ones whose tasks were interrupted, are in the first
+// lane, and then the ones whose members will be removed. The others are probably as
+// expected and are checked at last.
+func (bot *robot) laneOf(localRepo *models.Repo, expectRepo *expectRepoInfo) int {
+	s := localRepo.State()
+
+	if !s.Available || bot.interrupted.has(expectRepo.getNewRepoName()) {
+		return laneCreate
+	}
+
+	if bot.hasMembersToRemove(expectRepo, &s) {
+		return laneSecurity
+	}
+
+	return laneRoutine
+}
+
+// startWorkers starts the workers of each lane which handle the repos
+// in the queue until the queue is shut down.
+func (bot *robot) startWorkers(ctx context.Context) {
+	q := bot.queue
+
+	for lane, n := range bot.cfg.WorkQueue.LaneShares.workers(bot.cfg.ConcurrentSize) {
+		for i := 0; i < n; i++ {
+			bot.wg.Add(1)
+
+			go func(home int) {
+				defer bot.wg.Done()
+
+				for {
+					key, task, lane, ok := q.get(home)
+					if !ok {
+						return
+					}
+
+					q.wait(ctx)
+
+					if bot.handleRepoTask(ctx, task) {
+						q.forget(key)
+					} else if !isCancelled(ctx) {
+						q.retry(key, lane)
+					}
+
+					// The repo whose task is cancelled will be handled first next time.
+					bot.interrupted.set(key, isCancelled(ctx), task.log)
+
+					q.done(key)
+				}
+			}(lane)
+		}
+	}
+}
+
+// handleRepoTask returns true if the repo is handled without error.
+func (bot *robot) handleRepoTask(ctx context.Context, task *repoTask) bool {
+	localRepo := task.localRepo
+	log := task.log
+
+	expectRepo := task.expectRepo
+	expectRepo.result = &taskResult{}
+
 	f := func(before models.RepoState) models.RepoState {
 		if !before.Available {
-			return bot.createRepo(expectRepo, log, bot.createOBSMetaProject)
+			return bot.createRepo(ctx, expectRepo, log, bot.hooks)
 		}
 
-		return models.RepoState{
+		if bot.isRenamePending(expectRepo.expectRepoState) {
+			if err := bot.checkRenamed(ctx, &expectRepo, log); err != nil {
+				log.Error(err)
+				expectRepo.result.fail(stepRename, err)
+
+				return before
+			}
+		}
+
+		bot.syncOBSMetaProject(ctx, &expectRepo, log)
+
+		s := models.RepoState{
 			Available: true,
-			Branches:  bot.handleBranch(expectRepo, before.Branches, log),
-			Members:   bot.handleMember(expectRepo, before.Members, &before.Owner, log),
-			Property:  bot.updateRepo(expectRepo, before.Property, log),
+			Branches:  bot.handleBranch(ctx, expectRepo, before.Branches, log),
+			Members:   bot.handleMember(ctx, expectRepo, before.Members, &before.Owner, log),
+			Property:  bot.updateRepo(ctx, expectRepo, before.Property, log),
 			Owner:     before.Owner,
+
+			OwnersFileHash: bot.handleOwnersFile(ctx, &expectRepo, before.OwnersFileHash, log),
+		}
+
+		if bot.templates.has(expectRepo.getNewRepoName()) {
+			bot.applyRepoTemplate(ctx, &expectRepo, log)
 		}
+
+		if v := bot.failedHooks.get(expectRepo.getNewRepoName()); len(v) > 0 {
+			bot.failedHooks.set(
+				expectRepo.getNewRepoName(),
+				bot.hooks.runHooks(ctx, &expectRepo, sets.NewString(v...), log),
+				log,
+			)
+		}
+
+		bot.handleRepoStatus(ctx, &expectRepo, &s, log)
+
+		return s
 	}
 
-	bot.wg.Add(1)
-	err := bot.pool.Submit(func() {
-		defer bot.wg.Done()
+	repoName := expectRepo.getNewRepoName()
+
+	bot.tasks.add(repoName)
+	defer bot.tasks.remove(repoName)
+
+	if isCancelled(ctx) {
+		return false
+	}
+
+	localRepo.Update(func(before models.RepoState) (models.RepoState, []models.StepError) {
+		s := f(before)
+
+		reason := checkRepoConverged(&s, &expectRepo)
+		if reason != "" {
+			expectRepo.result.failf(stepVerify, "%s", reason)
+		}
+
+		bot.feedback.report(repoName, reason == "" && len(expectRepo.result.errors) == 0)
 
-		localRepo.Update(f)
+		return s, expectRepo.result.errors
 	})
-	if err != nil {
-		bot.wg.Done()
+
+	return len(expectRepo.result.errors) == 0
+}
+
+// sleep returns when the duration elapses or the context is done.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-t.C:
 	}
-	return err
 }
 
 func isCancelled(ctx context.Context) bool {
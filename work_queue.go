@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/robot-gitee-repo-watcher/models"
+)
+
+// The lanes of queued repos. The repos in the former lane are handled first.
+const (
+	// laneCreate is for creating, renaming and transferring repos.
+	laneCreate = iota
+	// laneSecurity is for removing the unexpected members of repos.
+	laneSecurity
+	// laneRoutine is for checking the repos which are probably as expected.
+	laneRoutine
+	laneCount
+)
+
+// repoTask is the latest expectation of a repo.
+type repoTask struct {
+	localRepo  *models.Repo
+	expectRepo expectRepoInfo
+	log        *logrus.Entry
+}
+
+// repoQueue is the work queue of repos in the style of client-go workqueue.
+// A repo is queued at most once no matter how many times it is added, and
+// it will be queued again if it is added while being handled, so it will
+// never be handled concurrently. The task of repo is always the latest one added.
+type repoQueue struct {
+	cfg *workQueue
+
+	lock sync.Mutex
+	cond *sync.Cond
+
+	// idle is signaled when the queue may become idle.
+	idle *sync.Cond
+
+	// lanes are the queued repos of each lane. A repo may stay in a lane
+	// after it is moved to a former lane, and it will be skipped when it is popped.
+	lanes [laneCount][]string
+
+	// dirty are the repos waiting to be handled and their lanes.
+	dirty      map[string]int
+	processing sets.String
+	tasks      map[string]*repoTask
+	failures   map[string]int
+
+	// next is the time that the next repo can be handled at.
+	next time.Time
+
+	shuttingDown bool
+}
+
+func newRepoQueue(cfg *workQueue) *repoQueue {
+	q := &repoQueue{
+		cfg:        cfg,
+		dirty:      make(map[string]int),
+		processing: sets.NewString(),
+		tasks:      make(map[string]*repoTask),
+		failures:   make(map[string]int),
+	}
+	q.cond = sync.NewCond(&q.lock)
+	q.idle = sync.NewCond(&q.lock)
+
+	return q
+}
+
+// add queues the repo with the latest task. The repo will be moved
+// if the lane is before the one which the repo is queued in.
+func (q *repoQueue) add(key string, task *repoTask, lane int) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.shuttingDown {
+		return fmt.Errorf("the queue is shut down")
+	}
+
+	q.tasks[key] = task
+	q.enqueue(key, lane)
+
+	return nil
+}
+
+// enqueue must be called with the lock held.
+func (q *repoQueue) enqueue(key string, lane int) {
+	if l, ok := q.dirty[key]; ok {
+		if lane < l {
+			q.dirty[key] = lane
+
+			if !q.processing.Has(key) {
+				q.lanes[lane] = append(q.lanes[lane], key)
+				q.cond.Broadcast()
+			}
+		}
+
+		return
+	}
+
+	q.dirty[key] = lane
+
+	// It will be queued when it is done.
+	if q.processing.Has(key) {
+		return
+	}
+
+	q.lanes[lane] = append(q.lanes[lane], key)
+	q.cond.Broadcast()
+}
+
+// get blocks until there is a repo to handle. The repo in the home lane is
+// handled first, otherwise the ones in the other lanes are handled in order.
+// It returns false if the queue is shut down. done must be called after the repo is handled.
+func (q *repoQueue) get(home int) (string, *repoTask, int, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for {
+		if q.shuttingDown {
+			return "", nil, 0, false
+		}
+
+		if key, ok := q.pop(home); ok {
+			return q.take(key, home)
+		}
+
+		for i := range q.lanes {
+			if key, ok := q.pop(i); ok {
+				return q.take(key, i)
+			}
+		}
+
+		q.cond.Wait()
+	}
+}
+
+func (q *repoQueue) take(key string, lane int) (string, *repoTask, int, bool) {
+	delete(q.dirty, key)
+	q.processing.Insert(key)
+
+	return key, q.tasks[key], lane, true
+}
+
+func (q *repoQueue) pop(lane int) (string, bool) {
+	for len(q.lanes[lane]) > 0 {
+		key := q.lanes[lane][0]
+		q.lanes[lane] = q.lanes[lane][1:]
+
+		// skip the one which was moved to another lane or has been popped.
+		if l, ok := q.dirty[key]; ok && l == lane && !q.processing.Has(key) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+func (q *repoQueue) done(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.processing.Delete(key)
+
+	if l, ok := q.dirty[key]; ok {
+		q.lanes[l] = append(q.lanes[l], key)
+		q.cond.Broadcast()
+	} else {
+		q.idle.Broadcast()
+	}
+}
+
+// retry queues the repo in the lane again after a delay which grows exponentially with the failures.
+func (q *repoQueue) retry(key string, lane int) {
+	q.lock.Lock()
+	n := q.failures[key]
+	q.failures[key] = n + 1
+	q.lock.Unlock()
+
+	time.AfterFunc(q.cfg.retryDelay(n), func() {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+
+		// the repo may be dropped during the delay.
+		if !q.shuttingDown && q.tasks[key] != nil {
+			q.enqueue(key, lane)
+		}
+	})
+}
+
+// drop removes the repo from the queue. It does not stop the one being handled.
+func (q *repoQueue) drop(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	delete(q.dirty, key)
+	delete(q.tasks, key)
+	delete(q.failures, key)
+
+	q.idle.Broadcast()
+}
+
+// retain removes the repos which are not in the keys, such as the ones removed
+// from the repo file, so that their stale tasks will not be retried.
+func (q *repoQueue) retain(keys sets.String) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for k := range q.tasks {
+		if !keys.Has(k) {
+			delete(q.dirty, k)
+			delete(q.tasks, k)
+			delete(q.failures, k)
+		}
+	}
+
+	q.idle.Broadcast()
+}
+
+// forget clears the failures and task of repo after it is handled successfully.
+// The task is kept if the repo is added again while being handled.
+func (q *repoQueue) forget(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	delete(q.failures, key)
+
+	if _, ok := q.dirty[key]; !ok {
+		delete(q.tasks, key)
+	}
+}
+
+// wait blocks until the next repo can be handled under the rate limit or the ctx is done.
+func (q *repoQueue) wait(ctx context.Context) {
+	if q.cfg.QPS <= 0 {
+		return
+	}
+
+	q.lock.Lock()
+	now := time.Now()
+	t := q.next
+	if t.Before(now) {
+		t = now
+	}
+	q.next = t.Add(time.Second / time.Duration(q.cfg.QPS))
+	q.lock.Unlock()
+
+	if d := t.Sub(now); d > 0 {
+		sleep(ctx, d)
+	}
+}
+
+// isIdle returns true if there is no repo queued or being handled.
+// It must be called with the lock held.
+func (q *repoQueue) isIdle() bool {
+	return len(q.dirty) == 0 && q.processing.Len() == 0
+}
+
+// waitIdle blocks until all the queued repos are handled, the queue is shut down or the ctx is done.
+func (q *repoQueue) waitIdle(ctx context.Context) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.lock.Lock()
+			q.idle.Broadcast()
+			q.lock.Unlock()
+		case <-stop:
+		}
+	}()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for !q.isIdle() && !q.shuttingDown && !isCancelled(ctx) {
+		q.idle.Wait()
+	}
+}
+
+// shutDown stops handing out the repos and returns the ones which are still queued.
+func (q *repoQueue) shutDown() []string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.shuttingDown = true
+	q.cond.Broadcast()
+	q.idle.Broadcast()
+
+	r := make([]string, 0, len(q.dirty))
+	for k := range q.dirty {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+
+	return r
+}
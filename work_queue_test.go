@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// getWithTimeout gets a repo from the queue or returns false if there is none in time.
+func getWithTimeout(q *repoQueue, home int) (string, *repoTask, int, bool) {
+	type item struct {
+		key  string
+		task *repoTask
+		lane int
+		ok   bool
+	}
+
+	c := make(chan item, 1)
+	go func() {
+		key, task, lane, ok := q.get(home)
+		c <- item{key, task, lane, ok}
+	}()
+
+	select {
+	case v := <-c:
+		return v.key, v.task, v.lane, v.ok
+	case <-time.After(time.Second):
+		q.shutDown()
+		<-c
+
+		return "", nil, 0, false
+	}
+}
+
+func TestRepoQueueLanes(t *testing.T) {
+	type queued struct {
+		key  string
+		lane int
+	}
+
+	cases := []struct {
+		name   string
+		queued []queued
+		home   int
+		expect []string
+	}{
+		{
+			name:   "the former lanes are handled first",
+			queued: []queued{{"a", laneRoutine}, {"b", laneSecurity}, {"c", laneCreate}},
+			home:   laneCreate,
+			expect: []string{"c", "b", "a"},
+		},
+		{
+			name:   "the home lane is handled first",
+			queued: []queued{{"a", laneRoutine}, {"b", laneSecurity}, {"c", laneCreate}},
+			home:   laneRoutine,
+			expect: []string{"a", "c", "b"},
+		},
+		{
+			name:   "the repo is moved to the former lane",
+			queued: []queued{{"a", laneRoutine}, {"b", laneRoutine}, {"b", laneCreate}},
+			home:   laneRoutine,
+			expect: []string{"a", "b"},
+		},
+		{
+			name:   "the repo is not moved to the latter lane",
+			queued: []queued{{"a", laneCreate}, {"b", laneRoutine}, {"a", laneRoutine}},
+			home:   laneRoutine,
+			expect: []string{"b", "a"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q := newRepoQueue(&workQueue{})
+
+			for _, item := range c.queued {
+				if err := q.add(item.key, &repoTask{}, item.lane); err != nil {
+					t.Fatalf("add %s, err:%s", item.key, err.Error())
+				}
+			}
+
+			r := []string{}
+			for range c.expect {
+				key, _, _, ok := getWithTimeout(q, c.home)
+				if !ok {
+					break
+				}
+
+				r = append(r, key)
+				q.done(key)
+			}
+
+			if !reflect.DeepEqual(r, c.expect) {
+				t.Errorf("expect %v, got %v", c.expect, r)
+			}
+
+			if v := q.shutDown(); len(v) != 0 {
+				t.Errorf("expect the queue to be empty, got %v", v)
+			}
+		})
+	}
+}
+
+func TestRepoQueueAddWhileProcessing(t *testing.T) {
+	q := newRepoQueue(&workQueue{})
+
+	first, second := &repoTask{}, &repoTask{}
+
+	q.add("a", first, laneRoutine)
+
+	key, task, _, _ := getWithTimeout(q, laneRoutine)
+	if key != "a" || task != first {
+		t.Fatalf("expect the first task of a, got %s", key)
+	}
+
+	q.add("a", second, laneRoutine)
+
+	// The repo is handled successfully, but the latest task is kept.
+	q.forget("a")
+	q.done("a")
+
+	key, task, _, _ = getWithTimeout(q, laneRoutine)
+	if key != "a" || task != second {
+		t.Fatalf("expect the second task of a, got %s", key)
+	}
+
+	q.forget("a")
+	q.done("a")
+
+	if len(q.tasks) != 0 {
+		t.Errorf("expect the task to be released, got %d tasks", len(q.tasks))
+	}
+}
+
+func TestRepoQueueRetry(t *testing.T) {
+	cases := []struct {
+		name     string
+		dropped  bool
+		expectOK bool
+	}{
+		{
+			name:     "the failed repo is queued again",
+			expectOK: true,
+		},
+		{
+			name:    "the repo dropped during the delay is not queued again",
+			dropped: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q := newRepoQueue(&workQueue{})
+
+			q.add("a", &repoTask{}, laneRoutine)
+			key, _, lane, _ := getWithTimeout(q, laneRoutine)
+
+			if c.dropped {
+				q.drop(key)
+			}
+
+			q.retry(key, lane)
+			q.done(key)
+
+			_, _, _, ok := getWithTimeout(q, laneRoutine)
+			if ok != c.expectOK {
+				t.Errorf("expect %t, got %t", c.expectOK, ok)
+			}
+		})
+	}
+}
+
+func TestWorkQueueRetryDelay(t *testing.T) {
+	w := workQueue{RetryBaseDelay: 30, RetryMaxDelay: 100}
+
+	cases := []struct {
+		failures int
+		expect   time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 60 * time.Second},
+		{2, 100 * time.Second},
+		{10, 100 * time.Second},
+	}
+
+	for _, c := range cases {
+		if v := w.retryDelay(c.failures); v != c.expect {
+			t.Errorf("failures:%d, expect %s, got %s", c.failures, c.expect, v)
+		}
+	}
+}
+
+func TestRepoQueueRetain(t *testing.T) {
+	q := newRepoQueue(&workQueue{})
+
+	for _, k := range []string{"a", "b", "c"} {
+		q.add(k, &repoTask{}, laneRoutine)
+	}
+
+	q.retain(sets.NewString("a", "c"))
+
+	if v := q.shutDown(); !reflect.DeepEqual(v, []string{"a", "c"}) {
+		t.Errorf("expect [a c], got %v", v)
+	}
+
+	if len(q.tasks) != 2 {
+		t.Errorf("expect 2 tasks, got %d", len(q.tasks))
+	}
+}
+
+func TestRepoQueueWaitIdle(t *testing.T) {
+	q := newRepoQueue(&workQueue{})
+	q.add("a", &repoTask{}, laneRoutine)
+
+	key, _, _, _ := getWithTimeout(q, laneRoutine)
+
+	done := make(chan struct{})
+	go func() {
+		q.waitIdle(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expect to wait for the repo being handled")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	q.done(key)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expect to return when the queue is idle")
+	}
+
+	// It returns when the ctx is done even if the queue is not idle.
+	q.add("b", &repoTask{}, laneRoutine)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done = make(chan struct{})
+	go func() {
+		q.waitIdle(ctx)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expect to return when the ctx is done")
+	}
+}